@@ -0,0 +1,1364 @@
+package main
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"sync"
+)
+
+const BLOCK_SIZE = 16
+
+// NIST Diffie-Hellman prime from challenge 33
+const NIST_P_HEX = "ffffffffffffffffc90fdaa22168c234c4c6628b80dc1cd129024" +
+	"e088a67cc74020bbea63b139b22514a08798e3404ddef9519b3cd3a431b" +
+	"302b0a6df25f14374fe1356d6d51c245e485b576625e7ec6f44c42e9a63" +
+	"7ed6b0bff5cb6f406b7edee386bfb5a899fa5ae9f24117c4b1fe649286651" +
+	"ece45b3dc2007cb8a163bf0598da48361c55d39a69163fa8fd24cf5f83655d" +
+	"23dca3ad961c62f356208552bb9ed529077096966d670c354e4abc9804f174" +
+	"6c08ca237327ffffffffffffffff"
+
+func NistP() *big.Int {
+	p, ok := new(big.Int).SetString(NIST_P_HEX, 16)
+	if !ok {
+		log.Fatal("unable to parse NIST prime")
+	}
+
+	return p
+}
+
+func NistG() *big.Int {
+	return big.NewInt(2)
+}
+
+func GenerateRandomBytes(byteLength int) []byte {
+	token := make([]byte, byteLength)
+
+	_, err := rand.Read(token)
+	if err != nil {
+		log.Fatalf("error generating random key: %v", err)
+	}
+
+	return token
+}
+
+// Random big.Int in [0, max)
+func GenerateRandomBigInt(max *big.Int) *big.Int {
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		log.Fatalf("error generating random number: %v", err)
+	}
+
+	return n
+}
+
+/*
+	Diffie-Hellman:
+		- private key "a" is a random number mod p
+		- public key "A" is g^a mod p
+		- both sides end up with the same secret: B^a = (g^b)^a = (g^a)^b = A^b
+*/
+func GenerateDHKeyPair(p *big.Int, g *big.Int) (*big.Int, *big.Int) {
+	private := GenerateRandomBigInt(p)
+	public := new(big.Int).Exp(g, private, p)
+
+	return private, public
+}
+
+func DHSharedSecret(otherPublic *big.Int, private *big.Int, p *big.Int) *big.Int {
+	return new(big.Int).Exp(otherPublic, private, p)
+}
+
+// AES key is the first 16 bytes of SHA1(s)
+func DHSessionKey(secret *big.Int) []byte {
+	hash := sha1.Sum(secret.Bytes())
+
+	return hash[:BLOCK_SIZE]
+}
+
+func xor(prevBlock []byte, currBlock []byte) []byte {
+	var xordBytes []byte = make([]byte, BLOCK_SIZE)
+
+	for i := 0; i < BLOCK_SIZE; i++ {
+		xordBytes[i] = prevBlock[i] ^ currBlock[i]
+	}
+
+	return xordBytes
+}
+
+// Pads to a multiple of BLOCK_SIZE, each padding byte is the amount of padding added
+func pkcs7Pad(data []byte) []byte {
+	padding := BLOCK_SIZE - len(data)%BLOCK_SIZE
+	padded := make([]byte, len(data), len(data)+padding)
+	copy(padded, data)
+
+	for i := 0; i < padding; i++ {
+		padded = append(padded, byte(padding))
+	}
+
+	return padded
+}
+
+func pkcs7Unpad(data []byte) ([]byte, error) {
+	if len(data) == 0 || len(data)%BLOCK_SIZE != 0 {
+		return nil, errors.New("invalid padded length")
+	}
+
+	padding := int(data[len(data)-1])
+	if padding == 0 || padding > BLOCK_SIZE {
+		return nil, errors.New("invalid padding")
+	}
+
+	for _, bite := range data[len(data)-padding:] {
+		if int(bite) != padding {
+			return nil, errors.New("invalid padding")
+		}
+	}
+
+	return data[:len(data)-padding], nil
+}
+
+/*
+	Same chaining as set 2, but the IV is passed in and the plaintext gets padded:
+		- XOR prev cipherText, starting with IV, with current plaintext block
+		- encrypt the result
+*/
+func encryptAESCBC(data []byte, key []byte, iv []byte) []byte {
+	cipher, err := aes.NewCipher(key)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	padded := pkcs7Pad(data)
+	encryptedBytes := make([]byte, len(padded))
+	amtOfBlocks := len(padded) / BLOCK_SIZE
+
+	previousBlock := iv
+
+	for i := 0; i < amtOfBlocks; i++ {
+		start := i * BLOCK_SIZE
+		end := (i + 1) * BLOCK_SIZE
+
+		xordBytes := xor(previousBlock, padded[start:end])
+		cipher.Encrypt(encryptedBytes[start:end], xordBytes)
+
+		previousBlock = encryptedBytes[start:end]
+	}
+
+	return encryptedBytes
+}
+
+func decryptAESCBC(cipheredBytes []byte, key []byte, iv []byte) ([]byte, error) {
+	cipher, err := aes.NewCipher(key)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(cipheredBytes)%BLOCK_SIZE != 0 {
+		return nil, errors.New("ciphertext is not a multiple of the block size")
+	}
+
+	plainTextBytes := make([]byte, len(cipheredBytes))
+	amtOfBlocks := len(cipheredBytes) / BLOCK_SIZE
+
+	prevBlock := iv
+
+	for i := 0; i < amtOfBlocks; i++ {
+		start := i * BLOCK_SIZE
+		end := (i + 1) * BLOCK_SIZE
+
+		decryptedBlock := make([]byte, BLOCK_SIZE)
+		cipher.Decrypt(decryptedBlock, cipheredBytes[start:end])
+
+		copy(plainTextBytes[start:end], xor(prevBlock, decryptedBlock))
+
+		prevBlock = cipheredBytes[start:end]
+	}
+
+	return pkcs7Unpad(plainTextBytes)
+}
+
+// Encrypts under the session key and sends ciphertext + iv as one payload
+func SealMessage(plainText []byte, key []byte) []byte {
+	iv := GenerateRandomBytes(BLOCK_SIZE)
+
+	return append(encryptAESCBC(plainText, key, iv), iv...)
+}
+
+func OpenMessage(payload []byte, key []byte) ([]byte, error) {
+	if len(payload) < 2*BLOCK_SIZE {
+		return nil, errors.New("payload too short")
+	}
+
+	split := len(payload) - BLOCK_SIZE
+
+	return decryptAESCBC(payload[:split], key, payload[split:])
+}
+
+/*
+	Protocol simulator:
+		- Every participant talks through a Link (an inbound and outbound channel)
+		- Alice and Bob don't know if they're connected to each other or to Mallory
+		- Mallory sits on the wire and gets to rewrite every message she relays
+*/
+const (
+	LABEL_GROUP     = "group"     // p, g, A (challenge 34)
+	LABEL_NEGOTIATE = "negotiate" // p, g (challenge 35)
+	LABEL_ACK       = "ack"       // p, g echoed back (challenge 35)
+	LABEL_KEY       = "key"       // a public key
+	LABEL_DATA      = "data"      // AES-CBC(msg) + iv
+)
+
+type Message struct {
+	Label string
+	Ints  []*big.Int
+	Data  []byte
+}
+
+// How many Ints each kind of message carries
+var LABEL_INTS = map[string]int{
+	LABEL_GROUP:     3,
+	LABEL_NEGOTIATE: 2,
+	LABEL_ACK:       2,
+	LABEL_KEY:       1,
+	LABEL_DATA:      0,
+}
+
+// Whether msg carries all the Ints its label needs (a rewritten message might not)
+func (msg Message) wellFormed() bool {
+	if len(msg.Ints) < LABEL_INTS[msg.Label] {
+		return false
+	}
+
+	for _, n := range msg.Ints[:LABEL_INTS[msg.Label]] {
+		if n == nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+type Link struct {
+	in  <-chan Message
+	out chan<- Message
+}
+
+// Two connected ends of a wire. Whatever is sent on one end is received on the other
+func NewLinkPair() (Link, Link) {
+	aToB := make(chan Message)
+	bToA := make(chan Message)
+
+	return Link{in: bToA, out: aToB}, Link{in: aToB, out: bToA}
+}
+
+func (link Link) Send(msg Message) {
+	link.out <- msg
+}
+
+func (link Link) Receive(label string) (Message, error) {
+	msg, ok := <-link.in
+	if !ok {
+		return Message{}, errors.New("connection closed")
+	}
+
+	if msg.Label != label {
+		return Message{}, fmt.Errorf("expected %q message, got %q", label, msg.Label)
+	}
+
+	if !msg.wellFormed() {
+		return Message{}, fmt.Errorf("%q message needs %d ints, got %d", label, LABEL_INTS[label], len(msg.Ints))
+	}
+
+	return msg, nil
+}
+
+// Hanging up lets the other side (or the relay) know nothing else is coming
+func (link Link) Close() {
+	close(link.out)
+}
+
+/*
+	Alice:
+		- negotiate == false (challenge 34): A->B p, g, A / B->A B
+		- negotiate == true (challenge 35):  A->B p, g / B->A ACK / A->B A / B->A B
+		- Sends AES-CBC(SHA1(s)[0:16], iv=random(16), msg) + iv and expects Bob to echo it back
+		- Returns the echo she decrypted
+*/
+func RunAlice(link Link, p *big.Int, g *big.Int, negotiate bool, plainText []byte) ([]byte, error) {
+	defer link.Close()
+
+	if negotiate {
+		link.Send(Message{Label: LABEL_NEGOTIATE, Ints: []*big.Int{p, g}})
+
+		ack, err := link.Receive(LABEL_ACK)
+		if err != nil {
+			return nil, err
+		}
+
+		// use whatever group Bob acknowledged
+		p, g = ack.Ints[0], ack.Ints[1]
+	}
+
+	a, A := GenerateDHKeyPair(p, g)
+
+	if negotiate {
+		link.Send(Message{Label: LABEL_KEY, Ints: []*big.Int{A}})
+	} else {
+		link.Send(Message{Label: LABEL_GROUP, Ints: []*big.Int{p, g, A}})
+	}
+
+	reply, err := link.Receive(LABEL_KEY)
+	if err != nil {
+		return nil, err
+	}
+
+	key := DHSessionKey(DHSharedSecret(reply.Ints[0], a, p))
+
+	link.Send(Message{Label: LABEL_DATA, Data: SealMessage(plainText, key)})
+
+	echo, err := link.Receive(LABEL_DATA)
+	if err != nil {
+		return nil, err
+	}
+
+	return OpenMessage(echo.Data, key)
+}
+
+// Bob mirrors Alice: completes the exchange, decrypts her message and re-encrypts it back to her
+func RunBob(link Link, negotiate bool) error {
+	defer link.Close()
+
+	var p, g, A *big.Int
+
+	if negotiate {
+		group, err := link.Receive(LABEL_NEGOTIATE)
+		if err != nil {
+			return err
+		}
+
+		p, g = group.Ints[0], group.Ints[1]
+		link.Send(Message{Label: LABEL_ACK, Ints: []*big.Int{p, g}})
+
+		key, err := link.Receive(LABEL_KEY)
+		if err != nil {
+			return err
+		}
+
+		A = key.Ints[0]
+	} else {
+		group, err := link.Receive(LABEL_GROUP)
+		if err != nil {
+			return err
+		}
+
+		p, g, A = group.Ints[0], group.Ints[1], group.Ints[2]
+	}
+
+	b, B := GenerateDHKeyPair(p, g)
+	link.Send(Message{Label: LABEL_KEY, Ints: []*big.Int{B}})
+
+	key := DHSessionKey(DHSharedSecret(A, b, p))
+
+	data, err := link.Receive(LABEL_DATA)
+	if err != nil {
+		return err
+	}
+
+	plainText, err := OpenMessage(data.Data, key)
+	if err != nil {
+		return err
+	}
+
+	link.Send(Message{Label: LABEL_DATA, Data: SealMessage(plainText, key)})
+
+	return nil
+}
+
+// Anything sitting on the wire. Gets every message and returns what to pass along
+type Interceptor interface {
+	Intercept(msg Message) Message
+}
+
+/*
+	Relays between Alice's and Bob's wires, running every message through the interceptor.
+	Returns once both sides have hung up.
+*/
+func RunRelay(aliceSide Link, bobSide Link, interceptor Interceptor) {
+	fromAlice, fromBob := aliceSide.in, bobSide.in
+
+	for fromAlice != nil || fromBob != nil {
+		select {
+		case msg, ok := <-fromAlice:
+			if !ok {
+				fromAlice = nil
+				bobSide.Close()
+				continue
+			}
+
+			bobSide.Send(interceptor.Intercept(msg))
+		case msg, ok := <-fromBob:
+			if !ok {
+				fromBob = nil
+				aliceSide.Close()
+				continue
+			}
+
+			aliceSide.Send(interceptor.Intercept(msg))
+		}
+	}
+}
+
+// Passes everything through untouched
+type PassiveWire struct{}
+
+func (PassiveWire) Intercept(msg Message) Message {
+	return msg
+}
+
+/*
+	Mallory:
+		- rewrite tampers with the key exchange
+		- secret works out the shared secret from the public keys she has seen on the wire
+		- every data message gets decrypted and recorded before it's relayed
+*/
+type Mallory struct {
+	rewrite   func(msg Message) Message
+	secret    func(publics []*big.Int) *big.Int
+	publics   []*big.Int
+	Recovered [][]byte
+	Errors    []error
+}
+
+func (m *Mallory) Intercept(msg Message) Message {
+	if msg.Label == LABEL_DATA {
+		key := DHSessionKey(m.secret(m.publics))
+
+		plainText, err := OpenMessage(msg.Data, key)
+		if err != nil {
+			m.Errors = append(m.Errors, err)
+		} else {
+			m.Recovered = append(m.Recovered, plainText)
+		}
+
+		return msg
+	}
+
+	// let malformed messages through as they are, the receiving side rejects them
+	if !msg.wellFormed() {
+		return msg
+	}
+
+	msg = m.rewrite(msg)
+
+	// keep track of the public keys that actually reached Alice and Bob
+	switch msg.Label {
+	case LABEL_GROUP:
+		m.publics = append(m.publics, msg.Ints[2])
+	case LABEL_KEY:
+		m.publics = append(m.publics, msg.Ints[0])
+	}
+
+	return msg
+}
+
+/*
+	Challenge 34 - key-fixing attack:
+		- Replace A and B with p
+		- Both sides compute p^x mod p = 0, so the shared secret is always 0
+*/
+func NewKeyFixingMallory(p *big.Int) *Mallory {
+	return &Mallory{
+		rewrite: func(msg Message) Message {
+			switch msg.Label {
+			case LABEL_GROUP:
+				return Message{Label: msg.Label, Ints: []*big.Int{msg.Ints[0], msg.Ints[1], p}}
+			case LABEL_KEY:
+				return Message{Label: msg.Label, Ints: []*big.Int{p}}
+			}
+
+			return msg
+		},
+		secret: func(publics []*big.Int) *big.Int {
+			return big.NewInt(0)
+		},
+	}
+}
+
+/*
+	Challenge 35 - malicious g:
+		- Swap g for maliciousG in the negotiation and the ACK so both sides use it
+		- g = 1:   every public key is 1, so s = 1
+		- g = p:   every public key is 0, so s = 0
+		- g = p-1: every public key is 1 or p-1, so s = 1 unless both public keys are p-1
+*/
+func NewMaliciousGMallory(p *big.Int, maliciousG *big.Int) *Mallory {
+	pMinusOne := new(big.Int).Sub(p, big.NewInt(1))
+
+	return &Mallory{
+		rewrite: func(msg Message) Message {
+			if msg.Label == LABEL_NEGOTIATE || msg.Label == LABEL_ACK {
+				return Message{Label: msg.Label, Ints: []*big.Int{msg.Ints[0], maliciousG}}
+			}
+
+			return msg
+		},
+		secret: func(publics []*big.Int) *big.Int {
+			switch {
+			case maliciousG.Cmp(big.NewInt(1)) == 0:
+				return big.NewInt(1)
+			case maliciousG.Cmp(p) == 0:
+				return big.NewInt(0)
+			}
+
+			for _, public := range publics {
+				if public.Cmp(pMinusOne) != 0 {
+					return big.NewInt(1)
+				}
+			}
+
+			return pMinusOne
+		},
+	}
+}
+
+/*
+	Wires up Alice -> interceptor -> Bob, runs the protocol and returns the echo Alice got back.
+	Returns the first error either side ran into.
+*/
+func SimulateDHExchange(p *big.Int, g *big.Int, negotiate bool, plainText []byte, interceptor Interceptor) ([]byte, error) {
+	alice, aliceSide := NewLinkPair()
+	bobSide, bob := NewLinkPair()
+
+	bobErr := make(chan error, 1)
+	go func() {
+		bobErr <- RunBob(bob, negotiate)
+	}()
+
+	relayDone := make(chan struct{})
+	go func() {
+		RunRelay(aliceSide, bobSide, interceptor)
+		close(relayDone)
+	}()
+
+	echo, err := RunAlice(alice, p, g, negotiate, plainText)
+
+	if errBob := <-bobErr; errBob != nil {
+		err = errBob
+	}
+
+	<-relayDone
+
+	return echo, err
+}
+
+func sha256Int(parts ...[]byte) *big.Int {
+	hash := sha256.New()
+
+	for _, part := range parts {
+		hash.Write(part)
+	}
+
+	return new(big.Int).SetBytes(hash.Sum(nil))
+}
+
+func hmacSHA256(key []byte, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+
+	return mac.Sum(nil)
+}
+
+// Left pads x with zeros to the byte length of N (a malicious A can be longer than N)
+func padToN(x *big.Int, N *big.Int) []byte {
+	if x.BitLen() > N.BitLen() {
+		return x.Bytes()
+	}
+
+	return x.FillBytes(make([]byte, len(N.Bytes())))
+}
+
+/*
+	SRP-6a (challenge 36):
+		- Both sides agree on N (NIST prime), g = 2 and k = H(N | PAD(g))
+		- The server never stores the password, only salt and verifier v = g^x where x = H(salt | password)
+		- Client: A = g^a
+		- Server: B = kv + g^b
+		- Both: u = H(PAD(A) | PAD(B))
+		- Client: S = (B - k * g^x)^(a + u * x)
+		- Server: S = (A * v^u)^b
+		- Both: K = H(S), client proves it knows K with HMAC-SHA256(K, salt)
+*/
+type SRPGroup struct {
+	N *big.Int
+	g *big.Int
+	k *big.Int
+}
+
+func NewSRPGroup(N *big.Int, g *big.Int) SRPGroup {
+	k := sha256Int(N.Bytes(), padToN(g, N))
+
+	return SRPGroup{N: N, g: g, k: k}
+}
+
+func (group SRPGroup) privateKey(salt []byte, password string) *big.Int {
+	return sha256Int(salt, []byte(password))
+}
+
+func (group SRPGroup) scrambler(A *big.Int, B *big.Int) *big.Int {
+	return sha256Int(padToN(A, group.N), padToN(B, group.N))
+}
+
+// Always 32 bytes, going through a big.Int would drop leading zeros
+func (group SRPGroup) sessionKey(S *big.Int) []byte {
+	K := sha256.Sum256(S.Bytes())
+
+	return K[:]
+}
+
+func (group SRPGroup) GenerateVerifier(password string) ([]byte, *big.Int) {
+	salt := GenerateRandomBytes(16)
+	x := group.privateKey(salt, password)
+
+	return salt, new(big.Int).Exp(group.g, x, group.N)
+}
+
+// Everything that goes over the wire. Each step fills in only the fields it needs
+type srpMessage struct {
+	Email string   `json:",omitempty"`
+	A     *big.Int `json:",omitempty"`
+	B     *big.Int `json:",omitempty"`
+	Salt  []byte   `json:",omitempty"`
+	U     *big.Int `json:",omitempty"`
+	Proof []byte   `json:",omitempty"`
+	OK    bool     `json:",omitempty"`
+	Error string   `json:",omitempty"`
+}
+
+type srpRecord struct {
+	salt     []byte
+	verifier *big.Int
+}
+
+type SRPServer struct {
+	group SRPGroup
+	users map[string]srpRecord
+
+	// Turns off the A % N != 0 check so the zero-key attack can be shown
+	SkipAValidation bool
+}
+
+func NewSRPServer(group SRPGroup) *SRPServer {
+	return &SRPServer{group: group, users: make(map[string]srpRecord)}
+}
+
+func (server *SRPServer) Register(email string, password string) {
+	salt, verifier := server.group.GenerateVerifier(password)
+
+	server.users[email] = srpRecord{salt: salt, verifier: verifier}
+}
+
+/*
+	Handles one login attempt on conn:
+		- C->S: I, A
+		- S->C: salt, B
+		- C->S: M1 = HMAC-SHA256(K, salt)
+		- S->C: OK and M2 = HMAC-SHA256(K, M1), or not OK
+	Returns whether the client proved it knows the password.
+*/
+func (server *SRPServer) Serve(conn net.Conn) (bool, error) {
+	defer conn.Close()
+
+	group := server.group
+	encoder := json.NewEncoder(conn)
+	decoder := json.NewDecoder(conn)
+
+	var hello srpMessage
+	if err := decoder.Decode(&hello); err != nil {
+		return false, err
+	}
+
+	record, ok := server.users[hello.Email]
+	if !ok || hello.A == nil {
+		encoder.Encode(srpMessage{Error: "unknown user"})
+		return false, errors.New("unknown user")
+	}
+
+	A := hello.A
+
+	// A = 0, N, 2N, ... would make S = 0 no matter what b is
+	if !server.SkipAValidation && new(big.Int).Mod(A, group.N).Sign() == 0 {
+		encoder.Encode(srpMessage{Error: "invalid A"})
+		return false, errors.New("invalid A")
+	}
+
+	b := GenerateRandomBigInt(group.N)
+	B := new(big.Int).Mul(group.k, record.verifier)
+	B.Add(B, new(big.Int).Exp(group.g, b, group.N))
+	B.Mod(B, group.N)
+
+	if err := encoder.Encode(srpMessage{Salt: record.salt, B: B}); err != nil {
+		return false, err
+	}
+
+	u := group.scrambler(A, B)
+
+	// S = (A * v^u)^b
+	S := new(big.Int).Exp(record.verifier, u, group.N)
+	S.Mul(S, A)
+	S.Exp(S, b, group.N)
+
+	K := group.sessionKey(S)
+
+	var proof srpMessage
+	if err := decoder.Decode(&proof); err != nil {
+		return false, err
+	}
+
+	authenticated := hmac.Equal(proof.Proof, hmacSHA256(K, record.salt))
+
+	result := srpMessage{OK: authenticated}
+
+	// M2 shows the client that we know the verifier too
+	if authenticated {
+		result.Proof = hmacSHA256(K, proof.Proof)
+	}
+
+	if err := encoder.Encode(result); err != nil {
+		return false, err
+	}
+
+	return authenticated, nil
+}
+
+// Runs the client side of a login on conn and returns whether the server accepted it
+func SRPLogin(conn net.Conn, group SRPGroup, email string, password string) (bool, error) {
+	a := GenerateRandomBigInt(group.N)
+	A := new(big.Int).Exp(group.g, a, group.N)
+
+	return srpClientExchange(conn, group, email, A, func(salt []byte, B *big.Int) *big.Int {
+		x := group.privateKey(salt, password)
+		u := group.scrambler(A, B)
+
+		// S = (B - k * g^x)^(a + u * x)
+		base := new(big.Int).Exp(group.g, x, group.N)
+		base.Mul(base, group.k)
+		base.Sub(B, base)
+		base.Mod(base, group.N)
+
+		exponent := new(big.Int).Mul(u, x)
+		exponent.Add(exponent, a)
+
+		return base.Exp(base, exponent, group.N)
+	})
+}
+
+/*
+	Challenge 37 - logging in without the password:
+		- Send A = 0, N, 2N, ... instead of g^a
+		- The server computes S = (A * v^u)^b mod N = 0
+		- So K = H(0) and the proof doesn't depend on the password at all
+*/
+func SRPLoginWithZeroKey(conn net.Conn, group SRPGroup, email string, maliciousA *big.Int) (bool, error) {
+	return srpClientExchange(conn, group, email, maliciousA, func(salt []byte, B *big.Int) *big.Int {
+		return big.NewInt(0)
+	})
+}
+
+func srpClientExchange(conn net.Conn, group SRPGroup, email string, A *big.Int, secret func(salt []byte, B *big.Int) *big.Int) (bool, error) {
+	defer conn.Close()
+
+	encoder := json.NewEncoder(conn)
+	decoder := json.NewDecoder(conn)
+
+	if err := encoder.Encode(srpMessage{Email: email, A: A}); err != nil {
+		return false, err
+	}
+
+	var challenge srpMessage
+	if err := decoder.Decode(&challenge); err != nil {
+		return false, err
+	}
+
+	if challenge.Error != "" {
+		return false, errors.New(challenge.Error)
+	}
+
+	// B = 0, N, 2N, ... or u = 0 would let a fake server pick S without knowing v
+	if challenge.B == nil || new(big.Int).Mod(challenge.B, group.N).Sign() == 0 {
+		return false, errors.New("invalid B")
+	}
+
+	if group.scrambler(A, challenge.B).Sign() == 0 {
+		return false, errors.New("invalid u")
+	}
+
+	K := group.sessionKey(secret(challenge.Salt, challenge.B))
+	proof := hmacSHA256(K, challenge.Salt)
+
+	if err := encoder.Encode(srpMessage{Proof: proof}); err != nil {
+		return false, err
+	}
+
+	var result srpMessage
+	if err := decoder.Decode(&result); err != nil {
+		return false, err
+	}
+
+	if !result.OK {
+		return false, nil
+	}
+
+	if !hmac.Equal(result.Proof, hmacSHA256(K, proof)) {
+		return false, errors.New("invalid server proof")
+	}
+
+	return true, nil
+}
+
+// Anything that can sit on the server end of a login
+type SRPHandler interface {
+	Serve(conn net.Conn) (bool, error)
+}
+
+/*
+	Connects a client to the server over net.Pipe and runs one login.
+	Returns what the client was told and what the server decided.
+*/
+func SimulateSRPLogin(server SRPHandler, client func(conn net.Conn) (bool, error)) (bool, bool, error) {
+	clientConn, serverConn := net.Pipe()
+
+	type serverResult struct {
+		ok  bool
+		err error
+	}
+
+	done := make(chan serverResult, 1)
+	go func() {
+		ok, err := server.Serve(serverConn)
+		done <- serverResult{ok, err}
+	}()
+
+	clientOK, clientErr := client(clientConn)
+	result := <-done
+
+	if result.err != nil {
+		return clientOK, result.ok, result.err
+	}
+
+	return clientOK, result.ok, clientErr
+}
+
+/*
+	Simplified SRP (challenge 38):
+		- Server: B = g^b and u is a random 128 bit number sent alongside salt and B
+		- Client: S = B^(a + u * x)
+		- Server: S = (A * v^u)^b
+		- B no longer depends on the verifier, so whoever plays the server controls every input to S
+*/
+type SimpleSRPServer struct {
+	group SRPGroup
+	users map[string]srpRecord
+}
+
+func NewSimpleSRPServer(group SRPGroup) *SimpleSRPServer {
+	return &SimpleSRPServer{group: group, users: make(map[string]srpRecord)}
+}
+
+func (server *SimpleSRPServer) Register(email string, password string) {
+	salt, verifier := server.group.GenerateVerifier(password)
+
+	server.users[email] = srpRecord{salt: salt, verifier: verifier}
+}
+
+func (server *SimpleSRPServer) Serve(conn net.Conn) (bool, error) {
+	defer conn.Close()
+
+	group := server.group
+	encoder := json.NewEncoder(conn)
+	decoder := json.NewDecoder(conn)
+
+	var hello srpMessage
+	if err := decoder.Decode(&hello); err != nil {
+		return false, err
+	}
+
+	record, ok := server.users[hello.Email]
+	if !ok || hello.A == nil {
+		encoder.Encode(srpMessage{Error: "unknown user"})
+		return false, errors.New("unknown user")
+	}
+
+	b := GenerateRandomBigInt(group.N)
+	B := new(big.Int).Exp(group.g, b, group.N)
+	u := new(big.Int).SetBytes(GenerateRandomBytes(16))
+
+	if err := encoder.Encode(srpMessage{Salt: record.salt, B: B, U: u}); err != nil {
+		return false, err
+	}
+
+	// S = (A * v^u)^b
+	S := new(big.Int).Exp(record.verifier, u, group.N)
+	S.Mul(S, hello.A)
+	S.Exp(S, b, group.N)
+
+	K := group.sessionKey(S)
+
+	var proof srpMessage
+	if err := decoder.Decode(&proof); err != nil {
+		return false, err
+	}
+
+	authenticated := hmac.Equal(proof.Proof, hmacSHA256(K, record.salt))
+
+	if err := encoder.Encode(srpMessage{OK: authenticated}); err != nil {
+		return false, err
+	}
+
+	return authenticated, nil
+}
+
+func SimpleSRPLogin(conn net.Conn, group SRPGroup, email string, password string) (bool, error) {
+	defer conn.Close()
+
+	encoder := json.NewEncoder(conn)
+	decoder := json.NewDecoder(conn)
+
+	a := GenerateRandomBigInt(group.N)
+	A := new(big.Int).Exp(group.g, a, group.N)
+
+	if err := encoder.Encode(srpMessage{Email: email, A: A}); err != nil {
+		return false, err
+	}
+
+	var challenge srpMessage
+	if err := decoder.Decode(&challenge); err != nil {
+		return false, err
+	}
+
+	if challenge.Error != "" {
+		return false, errors.New(challenge.Error)
+	}
+
+	x := group.privateKey(challenge.Salt, password)
+
+	// S = B^(a + u * x)
+	exponent := new(big.Int).Mul(challenge.U, x)
+	exponent.Add(exponent, a)
+	S := new(big.Int).Exp(challenge.B, exponent, group.N)
+
+	K := group.sessionKey(S)
+
+	if err := encoder.Encode(srpMessage{Proof: hmacSHA256(K, challenge.Salt)}); err != nil {
+		return false, err
+	}
+
+	var result srpMessage
+	if err := decoder.Decode(&result); err != nil {
+		return false, err
+	}
+
+	return result.OK, nil
+}
+
+/*
+	MITM for simplified SRP:
+		- Pretends to be the server and picks b = 1 (B = g), u = 1 and an empty salt
+		- The client then computes S = g^(a + x) = A * g^x
+		- Records A and the HMAC proof, then tells the client the login failed
+		- Every password guess only costs one exponentiation: S = A * g^H(salt | guess)
+*/
+type SimpleSRPMITM struct {
+	group SRPGroup
+	salt  []byte
+	b     *big.Int
+	B     *big.Int
+	u     *big.Int
+
+	Email string
+	A     *big.Int
+	Proof []byte
+}
+
+func NewSimpleSRPMITM(group SRPGroup) *SimpleSRPMITM {
+	b := big.NewInt(1)
+
+	return &SimpleSRPMITM{
+		group: group,
+		salt:  []byte{},
+		b:     b,
+		B:     new(big.Int).Exp(group.g, b, group.N),
+		u:     big.NewInt(1),
+	}
+}
+
+func (mitm *SimpleSRPMITM) Serve(conn net.Conn) (bool, error) {
+	defer conn.Close()
+
+	encoder := json.NewEncoder(conn)
+	decoder := json.NewDecoder(conn)
+
+	var hello srpMessage
+	if err := decoder.Decode(&hello); err != nil {
+		return false, err
+	}
+
+	if hello.A == nil {
+		encoder.Encode(srpMessage{Error: "missing A"})
+		return false, errors.New("missing A")
+	}
+
+	if err := encoder.Encode(srpMessage{Salt: mitm.salt, B: mitm.B, U: mitm.u}); err != nil {
+		return false, err
+	}
+
+	var proof srpMessage
+	if err := decoder.Decode(&proof); err != nil {
+		return false, err
+	}
+
+	mitm.Email, mitm.A, mitm.Proof = hello.Email, hello.A, proof.Proof
+
+	if err := encoder.Encode(srpMessage{OK: false}); err != nil {
+		return false, err
+	}
+
+	return false, nil
+}
+
+// Server side of the math with a guessed password: S = (A * v^u)^b, which is just A * g^H(salt | guess) since b = u = 1
+func (mitm *SimpleSRPMITM) checkPassword(guess string) bool {
+	group := mitm.group
+
+	S := new(big.Int).Exp(group.g, group.privateKey(mitm.salt, guess), group.N)
+	S.Mul(S, mitm.A)
+	S.Mod(S, group.N)
+
+	return hmac.Equal(mitm.Proof, hmacSHA256(group.sessionKey(S), mitm.salt))
+}
+
+/*
+	Offline dictionary attack on the captured proof:
+		- Reads one candidate password per line from wordlist
+		- Hands the candidates out to "workers" goroutines
+		- Stops feeding candidates as soon as one of them matches
+	Returns the password and whether it was found.
+*/
+func (mitm *SimpleSRPMITM) CrackPassword(wordlist io.Reader, workers int) (string, bool, error) {
+	if mitm.Proof == nil {
+		return "", false, errors.New("no proof captured yet")
+	}
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	words := make(chan string)
+	found := make(chan string, 1)
+	done := make(chan struct{})
+	var once sync.Once
+	var wg sync.WaitGroup
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for word := range words {
+				if mitm.checkPassword(word) {
+					once.Do(func() {
+						found <- word
+						close(done)
+					})
+				}
+			}
+		}()
+	}
+
+	scanner := bufio.NewScanner(wordlist)
+
+feed:
+	for scanner.Scan() {
+		select {
+		case words <- scanner.Text():
+		case <-done:
+			break feed
+		}
+	}
+
+	close(words)
+	wg.Wait()
+
+	select {
+	case password := <-found:
+		return password, true, nil
+	default:
+		return "", false, scanner.Err()
+	}
+}
+
+/*
+	Modular inverse with the extended Euclidean algorithm:
+		- Keeps a = x * a0 (mod m) while running Euclid on (a, m)
+		- When the remainder hits 1, x is the inverse
+		- If the gcd isn't 1 there is no inverse
+*/
+func InvMod(a *big.Int, m *big.Int) (*big.Int, error) {
+	if m.Sign() <= 0 {
+		return nil, errors.New("modulus must be positive")
+	}
+
+	oldR, r := new(big.Int).Mod(a, m), new(big.Int).Set(m)
+	oldX, x := big.NewInt(1), big.NewInt(0)
+
+	for r.Sign() != 0 {
+		quotient := new(big.Int).Div(oldR, r)
+
+		oldR, r = r, new(big.Int).Sub(oldR, new(big.Int).Mul(quotient, r))
+		oldX, x = x, new(big.Int).Sub(oldX, new(big.Int).Mul(quotient, x))
+	}
+
+	if oldR.Cmp(big.NewInt(1)) != 0 {
+		return nil, fmt.Errorf("%v has no inverse mod %v", a, m)
+	}
+
+	return oldX.Mod(oldX, m), nil
+}
+
+// Random prime with exactly "bits" bits (top two bits set so p * q has the full size)
+func GeneratePrime(bits int) *big.Int {
+	if bits < 3 {
+		log.Fatalf("can't generate a %d bit prime", bits)
+	}
+
+	for {
+		candidate := new(big.Int).SetBytes(GenerateRandomBytes((bits + 7) / 8))
+
+		// trim to size, force the top two bits and make it odd
+		candidate.Rsh(candidate, uint((bits+7)/8*8-bits))
+		candidate.SetBit(candidate, bits-1, 1)
+		candidate.SetBit(candidate, bits-2, 1)
+		candidate.SetBit(candidate, 0, 1)
+
+		if candidate.BitLen() == bits && candidate.ProbablyPrime(20) {
+			return candidate
+		}
+	}
+}
+
+const RSA_DEFAULT_E = 3
+
+type RSAPublicKey struct {
+	E *big.Int
+	N *big.Int
+}
+
+type RSAPrivateKey struct {
+	RSAPublicKey
+	D *big.Int
+	P *big.Int
+	Q *big.Int
+}
+
+// Textbook RSA with the default e = 3, which is what the attacks in this set want
+func GenerateRSAKey(bits int) RSAPrivateKey {
+	key, err := GenerateRSAKeyWithExponent(bits, RSA_DEFAULT_E)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return key
+}
+
+/*
+	Textbook RSA key generation:
+		- pick primes p and q, n = p * q
+		- et = (p - 1) * (q - 1)
+		- d = invmod(e, et), start over if e and et aren't coprime
+	e has to be odd and at least 3, otherwise et (always even) never is.
+*/
+func GenerateRSAKeyWithExponent(bits int, e int64) (RSAPrivateKey, error) {
+	if e < 3 || e%2 == 0 {
+		return RSAPrivateKey{}, fmt.Errorf("invalid public exponent %d", e)
+	}
+
+	E := big.NewInt(e)
+	one := big.NewInt(1)
+
+	for {
+		p := GeneratePrime(bits / 2)
+		q := GeneratePrime(bits - bits/2)
+
+		if p.Cmp(q) == 0 {
+			continue
+		}
+
+		et := new(big.Int).Mul(new(big.Int).Sub(p, one), new(big.Int).Sub(q, one))
+
+		d, err := InvMod(E, et)
+		if err != nil {
+			continue
+		}
+
+		return RSAPrivateKey{
+			RSAPublicKey: RSAPublicKey{E: E, N: new(big.Int).Mul(p, q)},
+			D:            d,
+			P:            p,
+			Q:            q,
+		}, nil
+	}
+}
+
+// c = m^e mod n
+func (key RSAPublicKey) Encrypt(m *big.Int) *big.Int {
+	return new(big.Int).Exp(m, key.E, key.N)
+}
+
+// m = c^d mod n
+func (key RSAPrivateKey) Decrypt(c *big.Int) *big.Int {
+	return new(big.Int).Exp(c, key.D, key.N)
+}
+
+// Treats the bytes as a big-endian number. No padding, so the message has to be smaller than n
+func (key RSAPublicKey) EncryptBytes(message []byte) ([]byte, error) {
+	m := new(big.Int).SetBytes(message)
+
+	if m.Cmp(key.N) >= 0 {
+		return nil, errors.New("message too long for key size")
+	}
+
+	return key.Encrypt(m).Bytes(), nil
+}
+
+// Leading zero bytes of the original message don't survive the round trip
+func (key RSAPrivateKey) DecryptBytes(cipherText []byte) []byte {
+	return key.Decrypt(new(big.Int).SetBytes(cipherText)).Bytes()
+}
+
+/*
+	Chinese Remainder Theorem:
+		- Finds x with x = residues[i] mod moduli[i] for every i (moduli pairwise coprime)
+		- x = sum(r_i * m_s_i * invmod(m_s_i, n_i)) mod N, where m_s_i is the product of every modulus except n_i
+	Returns x and N, the product of all the moduli.
+*/
+func CRT(residues []*big.Int, moduli []*big.Int) (*big.Int, *big.Int, error) {
+	if len(residues) != len(moduli) || len(moduli) == 0 {
+		return nil, nil, errors.New("need one residue per modulus")
+	}
+
+	product := big.NewInt(1)
+	for _, modulus := range moduli {
+		product.Mul(product, modulus)
+	}
+
+	result := big.NewInt(0)
+
+	for i, residue := range residues {
+		ms := new(big.Int).Div(product, moduli[i])
+
+		inverse, err := InvMod(ms, moduli[i])
+		if err != nil {
+			return nil, nil, fmt.Errorf("moduli aren't coprime: %v", err)
+		}
+
+		term := new(big.Int).Mul(residue, ms)
+		term.Mul(term, inverse)
+		result.Add(result, term)
+	}
+
+	return result.Mod(result, product), product, nil
+}
+
+/*
+	Integer n-th root with Newton's method:
+		- starts above the root and steps down with r = ((n - 1) * r + x / r^(n-1)) / n
+		- stops once r stops shrinking, which leaves floor(x^(1/n))
+	Returns the floor of the root and whether it was exact.
+*/
+func NthRoot(x *big.Int, n int) (*big.Int, bool) {
+	if x.Sign() < 0 || n < 1 {
+		log.Fatalf("can't take root %d of %v", n, x)
+	}
+
+	if x.Sign() == 0 || n == 1 {
+		return new(big.Int).Set(x), true
+	}
+
+	N := big.NewInt(int64(n))
+	nMinusOne := big.NewInt(int64(n - 1))
+
+	// 2^ceil(bits / n) is always >= the root
+	root := new(big.Int).Lsh(big.NewInt(1), uint((x.BitLen()+n-1)/n))
+
+	for {
+		next := new(big.Int).Exp(root, nMinusOne, nil)
+		next.Div(x, next)
+		next.Add(next, new(big.Int).Mul(nMinusOne, root))
+		next.Div(next, N)
+
+		if next.Cmp(root) >= 0 {
+			break
+		}
+
+		root = next
+	}
+
+	exact := new(big.Int).Exp(root, N, nil).Cmp(x) == 0
+
+	return root, exact
+}
+
+func CubeRoot(x *big.Int) (*big.Int, bool) {
+	return NthRoot(x, 3)
+}
+
+type HastadResult struct {
+	PlainText []byte
+	Exact     bool
+}
+
+/*
+	Hastad's broadcast attack (challenge 40):
+		- The same unpadded message is encrypted to e recipients, all using e as the public exponent
+		- CRT gives m^e mod (n_0 * n_1 * ... ), and m^e is smaller than that product
+		- So the e-th root over the integers is m. If the root isn't exact the attack didn't work
+*/
+func HastadBroadcastAttack(cipherTexts []*big.Int, keys []RSAPublicKey) (HastadResult, error) {
+	if len(cipherTexts) != len(keys) || len(keys) == 0 {
+		return HastadResult{}, errors.New("need one ciphertext per public key")
+	}
+
+	e := keys[0].E
+	if !e.IsInt64() || len(keys) < int(e.Int64()) {
+		return HastadResult{}, fmt.Errorf("need at least e = %v ciphertexts", e)
+	}
+
+	moduli := make([]*big.Int, 0, len(keys))
+	for _, key := range keys {
+		if key.E.Cmp(e) != 0 {
+			return HastadResult{}, errors.New("every recipient has to use the same e")
+		}
+
+		moduli = append(moduli, key.N)
+	}
+
+	mToTheE, _, err := CRT(cipherTexts, moduli)
+	if err != nil {
+		return HastadResult{}, err
+	}
+
+	m, exact := NthRoot(mToTheE, int(e.Int64()))
+
+	return HastadResult{PlainText: m.Bytes(), Exact: exact}, nil
+}
@@ -0,0 +1,418 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDHProtocolWithoutMITM(t *testing.T) {
+	message := []byte("Ice Ice Baby")
+
+	echo, err := SimulateDHExchange(NistP(), NistG(), false, message, PassiveWire{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, message, echo)
+}
+
+// Drops the last Int of every message it relays
+type truncatingWire struct{}
+
+func (truncatingWire) Intercept(msg Message) Message {
+	if len(msg.Ints) > 0 {
+		msg.Ints = msg.Ints[:len(msg.Ints)-1]
+	}
+
+	return msg
+}
+
+func TestDHProtocolRejectsShortMessages(t *testing.T) {
+	for _, negotiate := range []bool{false, true} {
+		_, err := SimulateDHExchange(NistP(), NistG(), negotiate, []byte("Ice Ice Baby"), truncatingWire{})
+
+		assert.NotNil(t, err)
+	}
+
+	// Mallory relays short messages as they are instead of rewriting them
+	mallory := NewKeyFixingMallory(NistP())
+	short := Message{Label: LABEL_GROUP, Ints: []*big.Int{NistP()}}
+
+	assert.Equal(t, short, mallory.Intercept(short))
+}
+
+func TestDHKeyFixingAttack(t *testing.T) {
+	/*
+		- Mallory swaps A and B for p on the wire
+		- (p ^ x) mod p == 0, so both sides derive their key from s = 0
+		- Mallory can read everything and the echo still works
+	*/
+	message := []byte("Rollin' in my 5.0")
+	mallory := NewKeyFixingMallory(NistP())
+
+	echo, err := SimulateDHExchange(NistP(), NistG(), false, message, mallory)
+
+	assert.Nil(t, err)
+	assert.Equal(t, message, echo)
+	assert.Equal(t, [][]byte{message, message}, mallory.Recovered)
+}
+
+func TestDHMaliciousGAttack(t *testing.T) {
+	p := NistP()
+	message := []byte("With my ragtop down so my hair can blow")
+
+	maliciousGs := []*big.Int{
+		big.NewInt(1),
+		p,
+		new(big.Int).Sub(p, big.NewInt(1)),
+	}
+
+	for _, maliciousG := range maliciousGs {
+		mallory := NewMaliciousGMallory(p, maliciousG)
+
+		echo, err := SimulateDHExchange(p, NistG(), true, message, mallory)
+
+		fmt.Printf("g: %v recovered: %q\n", maliciousG, mallory.Recovered)
+
+		assert.Nil(t, err)
+		assert.Equal(t, message, echo)
+		assert.Empty(t, mallory.Errors)
+		assert.Equal(t, [][]byte{message, message}, mallory.Recovered)
+	}
+}
+
+func TestSRPLogin(t *testing.T) {
+	group := NewSRPGroup(NistP(), NistG())
+	server := NewSRPServer(group)
+	server.Register("vanilla@ice.com", "to the extreme")
+
+	clientOK, serverOK, err := SimulateSRPLogin(server, func(conn net.Conn) (bool, error) {
+		return SRPLogin(conn, group, "vanilla@ice.com", "to the extreme")
+	})
+
+	assert.Nil(t, err)
+	assert.True(t, clientOK)
+	assert.True(t, serverOK)
+
+	clientOK, serverOK, err = SimulateSRPLogin(server, func(conn net.Conn) (bool, error) {
+		return SRPLogin(conn, group, "vanilla@ice.com", "wrong password")
+	})
+
+	assert.Nil(t, err)
+	assert.False(t, clientOK)
+	assert.False(t, serverOK)
+}
+
+// Claims every login succeeded without knowing the verifier
+type fakeSRPServer struct {
+	B *big.Int
+}
+
+func (server fakeSRPServer) Serve(conn net.Conn) (bool, error) {
+	defer conn.Close()
+
+	encoder := json.NewEncoder(conn)
+	decoder := json.NewDecoder(conn)
+
+	var message srpMessage
+	if err := decoder.Decode(&message); err != nil {
+		return false, nil
+	}
+
+	if err := encoder.Encode(srpMessage{Salt: []byte("salt"), B: server.B}); err != nil {
+		return false, nil
+	}
+
+	if err := decoder.Decode(&message); err != nil {
+		return false, nil
+	}
+
+	encoder.Encode(srpMessage{OK: true})
+
+	return true, nil
+}
+
+func TestSRPSessionKeyLength(t *testing.T) {
+	// about 1 in 256 hashes starts with a zero byte, 2048 tries are bound to hit a few
+	group := NewSRPGroup(NistP(), NistG())
+
+	for i := int64(0); i < 2048; i++ {
+		assert.Len(t, group.sessionKey(big.NewInt(i)), sha256.Size)
+	}
+}
+
+func TestSRPLoginRejectsFakeServer(t *testing.T) {
+	group := NewSRPGroup(NistP(), NistG())
+
+	for _, B := range []*big.Int{big.NewInt(0), group.N} {
+		clientOK, _, err := SimulateSRPLogin(fakeSRPServer{B: B}, func(conn net.Conn) (bool, error) {
+			return SRPLogin(conn, group, "vanilla@ice.com", "to the extreme")
+		})
+
+		assert.EqualError(t, err, "invalid B")
+		assert.False(t, clientOK)
+	}
+
+	// A well formed B gets through, but the server can't produce M2
+	clientOK, _, err := SimulateSRPLogin(fakeSRPServer{B: group.g}, func(conn net.Conn) (bool, error) {
+		return SRPLogin(conn, group, "vanilla@ice.com", "to the extreme")
+	})
+
+	assert.EqualError(t, err, "invalid server proof")
+	assert.False(t, clientOK)
+}
+
+func TestSRPZeroKeyAttack(t *testing.T) {
+	/*
+		- Client sends A = 0, N or 2N
+		- Server computes S = (A * v^u)^b mod N which is always 0
+		- Client never needs the password, K = H(0)
+	*/
+	group := NewSRPGroup(NistP(), NistG())
+	maliciousAs := []*big.Int{
+		big.NewInt(0),
+		group.N,
+		new(big.Int).Mul(group.N, big.NewInt(2)),
+	}
+
+	vulnerable := NewSRPServer(group)
+	vulnerable.SkipAValidation = true
+	vulnerable.Register("vanilla@ice.com", "to the extreme")
+
+	strict := NewSRPServer(group)
+	strict.Register("vanilla@ice.com", "to the extreme")
+
+	for _, maliciousA := range maliciousAs {
+		clientOK, serverOK, err := SimulateSRPLogin(vulnerable, func(conn net.Conn) (bool, error) {
+			return SRPLoginWithZeroKey(conn, group, "vanilla@ice.com", maliciousA)
+		})
+
+		assert.Nil(t, err)
+		assert.True(t, clientOK)
+		assert.True(t, serverOK)
+
+		_, serverOK, err = SimulateSRPLogin(strict, func(conn net.Conn) (bool, error) {
+			return SRPLoginWithZeroKey(conn, group, "vanilla@ice.com", maliciousA)
+		})
+
+		assert.NotNil(t, err)
+		assert.False(t, serverOK)
+	}
+}
+
+func TestSimpleSRPLogin(t *testing.T) {
+	group := NewSRPGroup(NistP(), NistG())
+	server := NewSimpleSRPServer(group)
+	server.Register("vanilla@ice.com", "ninja")
+
+	clientOK, serverOK, err := SimulateSRPLogin(server, func(conn net.Conn) (bool, error) {
+		return SimpleSRPLogin(conn, group, "vanilla@ice.com", "ninja")
+	})
+
+	assert.Nil(t, err)
+	assert.True(t, clientOK)
+	assert.True(t, serverOK)
+}
+
+func TestSimpleSRPOfflineDictionaryAttack(t *testing.T) {
+	/*
+		- Client logs in to Mallory instead of the real server
+		- Mallory picks b = 1, u = 1, salt = "" so S only depends on A and the password
+		- Mallory cracks the captured HMAC against a wordlist
+	*/
+	group := NewSRPGroup(NistP(), NistG())
+	mitm := NewSimpleSRPMITM(group)
+
+	_, _, err := SimulateSRPLogin(mitm, func(conn net.Conn) (bool, error) {
+		return SimpleSRPLogin(conn, group, "vanilla@ice.com", "ninja")
+	})
+	assert.Nil(t, err)
+
+	words := make([]string, 0)
+	for i := 0; i < 500; i++ {
+		words = append(words, fmt.Sprintf("password%d", i))
+	}
+	words = append(words, "ninja", "turtle")
+
+	password, found, err := mitm.CrackPassword(strings.NewReader(strings.Join(words, "\n")), 8)
+
+	fmt.Printf("cracked password for %v: %v\n", mitm.Email, password)
+
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "ninja", password)
+
+	_, found, err = mitm.CrackPassword(strings.NewReader("hunter2\nletmein"), 2)
+
+	assert.Nil(t, err)
+	assert.False(t, found)
+}
+
+func TestInvMod(t *testing.T) {
+	inverse, err := InvMod(big.NewInt(17), big.NewInt(3120))
+
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(2753), inverse)
+
+	_, err = InvMod(big.NewInt(6), big.NewInt(9))
+
+	assert.NotNil(t, err)
+}
+
+func TestTextbookRSA(t *testing.T) {
+	key := GenerateRSAKey(1024)
+
+	assert.Equal(t, big.NewInt(3), key.E)
+	assert.Equal(t, 1024, key.N.BitLen())
+
+	m := big.NewInt(42)
+	assert.Equal(t, m, key.Decrypt(key.Encrypt(m)))
+
+	message := []byte("Cooking MC's like a pound of bacon")
+	cipherText, err := key.EncryptBytes(message)
+
+	assert.Nil(t, err)
+	assert.Equal(t, message, key.DecryptBytes(cipherText))
+
+	_, err = key.EncryptBytes(append(key.N.Bytes(), 0))
+
+	assert.NotNil(t, err)
+}
+
+func TestGeneratePrime(t *testing.T) {
+	// sizes that aren't a whole number of bytes, where the random top byte can be 0
+	for _, bits := range []int{3, 9, 17, 100} {
+		for i := 0; i < 50; i++ {
+			p := GeneratePrime(bits)
+
+			assert.Equal(t, bits, p.BitLen())
+			assert.True(t, p.ProbablyPrime(20))
+		}
+	}
+}
+
+func TestGenerateRSAKeyRejectsBadExponent(t *testing.T) {
+	for _, e := range []int64{-3, 0, 1, 2, 65536} {
+		_, err := GenerateRSAKeyWithExponent(512, e)
+
+		assert.NotNil(t, err)
+	}
+}
+
+func TestTextbookRSAAgainstCryptoRSA(t *testing.T) {
+	/*
+		- Our key with e = 65537 should be a valid crypto/rsa key
+		- crypto/rsa encrypts with our public key and decrypts with our private key
+		- crypto/rsa keys decrypt with our textbook math
+	*/
+	ours, err := GenerateRSAKeyWithExponent(2048, 65537)
+	assert.Nil(t, err)
+
+	stdKey := &rsa.PrivateKey{
+		PublicKey: rsa.PublicKey{N: ours.N, E: int(ours.E.Int64())},
+		D:         ours.D,
+		Primes:    []*big.Int{ours.P, ours.Q},
+	}
+	stdKey.Precompute()
+
+	assert.Nil(t, stdKey.Validate())
+
+	message := []byte("Play that funky music white boy")
+	cipherText, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, &stdKey.PublicKey, message, nil)
+	assert.Nil(t, err)
+
+	plainText, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, stdKey, cipherText, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, message, plainText)
+
+	theirs, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+
+	// our InvMod should find a working d for crypto/rsa's primes
+	one := big.NewInt(1)
+	p, q := theirs.Primes[0], theirs.Primes[1]
+	et := new(big.Int).Mul(new(big.Int).Sub(p, one), new(big.Int).Sub(q, one))
+	d, err := InvMod(big.NewInt(int64(theirs.E)), et)
+	assert.Nil(t, err)
+
+	theirKey := RSAPrivateKey{
+		RSAPublicKey: RSAPublicKey{E: big.NewInt(int64(theirs.E)), N: theirs.N},
+		D:            d,
+	}
+
+	m := new(big.Int).SetBytes(message)
+	assert.Equal(t, m, theirKey.Decrypt(theirKey.Encrypt(m)))
+	assert.Equal(t, m, new(big.Int).Exp(theirKey.Encrypt(m), theirs.D, theirs.N))
+}
+
+func TestCRT(t *testing.T) {
+	// x = 2 mod 3, x = 3 mod 5, x = 2 mod 7
+	x, product, err := CRT(
+		[]*big.Int{big.NewInt(2), big.NewInt(3), big.NewInt(2)},
+		[]*big.Int{big.NewInt(3), big.NewInt(5), big.NewInt(7)},
+	)
+
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(23), x)
+	assert.Equal(t, big.NewInt(105), product)
+
+	_, _, err = CRT([]*big.Int{big.NewInt(1), big.NewInt(2)}, []*big.Int{big.NewInt(4), big.NewInt(6)})
+
+	assert.NotNil(t, err)
+}
+
+func TestNthRoot(t *testing.T) {
+	cube := new(big.Int).Exp(big.NewInt(123456789), big.NewInt(3), nil)
+
+	root, exact := CubeRoot(cube)
+	assert.Equal(t, big.NewInt(123456789), root)
+	assert.True(t, exact)
+
+	root, exact = CubeRoot(new(big.Int).Add(cube, big.NewInt(1)))
+	assert.Equal(t, big.NewInt(123456789), root)
+	assert.False(t, exact)
+
+	root, exact = NthRoot(big.NewInt(1<<20), 5)
+	assert.Equal(t, big.NewInt(16), root)
+	assert.True(t, exact)
+}
+
+func TestHastadBroadcastAttack(t *testing.T) {
+	/*
+		- Same message encrypted to three different e = 3 public keys
+		- CRT the three ciphertexts and take the cube root
+	*/
+	// long enough that m^3 wraps around every single modulus
+	message := []byte("Word to your mother, this message is long enough to need the CRT")
+
+	cipherTexts := make([]*big.Int, 0)
+	keys := make([]RSAPublicKey, 0)
+
+	for i := 0; i < 3; i++ {
+		key := GenerateRSAKey(1024)
+		cipherText, err := key.EncryptBytes(message)
+		assert.Nil(t, err)
+
+		cipherTexts = append(cipherTexts, new(big.Int).SetBytes(cipherText))
+		keys = append(keys, key.RSAPublicKey)
+	}
+
+	result, err := HastadBroadcastAttack(cipherTexts, keys)
+
+	fmt.Printf("recovered: %q exact: %v\n", result.PlainText, result.Exact)
+
+	assert.Nil(t, err)
+	assert.True(t, result.Exact)
+	assert.Equal(t, message, result.PlainText)
+
+	_, err = HastadBroadcastAttack(cipherTexts[:2], keys[:2])
+
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,1315 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"strings"
+	"time"
+)
+
+func GenerateRandomBytes(byteLength int) []byte {
+	token := make([]byte, byteLength)
+
+	_, err := rand.Read(token)
+	if err != nil {
+		log.Fatalf("error generating random key: %v", err)
+	}
+
+	return token
+}
+
+// Random big.Int in [0, max)
+func GenerateRandomBigInt(max *big.Int) *big.Int {
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		log.Fatalf("error generating random number: %v", err)
+	}
+
+	return n
+}
+
+/*
+	Modular inverse with the extended Euclidean algorithm:
+		- Keeps a = x * a0 (mod m) while running Euclid on (a, m)
+		- When the remainder hits 1, x is the inverse
+		- If the gcd isn't 1 there is no inverse
+*/
+func InvMod(a *big.Int, m *big.Int) (*big.Int, error) {
+	if m.Sign() <= 0 {
+		return nil, errors.New("modulus must be positive")
+	}
+
+	oldR, r := new(big.Int).Mod(a, m), new(big.Int).Set(m)
+	oldX, x := big.NewInt(1), big.NewInt(0)
+
+	for r.Sign() != 0 {
+		quotient := new(big.Int).Div(oldR, r)
+
+		oldR, r = r, new(big.Int).Sub(oldR, new(big.Int).Mul(quotient, r))
+		oldX, x = x, new(big.Int).Sub(oldX, new(big.Int).Mul(quotient, x))
+	}
+
+	if oldR.Cmp(big.NewInt(1)) != 0 {
+		return nil, fmt.Errorf("%v has no inverse mod %v", a, m)
+	}
+
+	return oldX.Mod(oldX, m), nil
+}
+
+// Random prime with exactly "bits" bits (top two bits set so p * q has the full size)
+func GeneratePrime(bits int) *big.Int {
+	if bits < 3 {
+		log.Fatalf("can't generate a %d bit prime", bits)
+	}
+
+	for {
+		candidate := new(big.Int).SetBytes(GenerateRandomBytes((bits + 7) / 8))
+
+		// trim to size, force the top two bits and make it odd
+		candidate.Rsh(candidate, uint((bits+7)/8*8-bits))
+		candidate.SetBit(candidate, bits-1, 1)
+		candidate.SetBit(candidate, bits-2, 1)
+		candidate.SetBit(candidate, 0, 1)
+
+		if candidate.BitLen() == bits && candidate.ProbablyPrime(20) {
+			return candidate
+		}
+	}
+}
+
+const RSA_DEFAULT_E = 3
+
+type RSAPublicKey struct {
+	E *big.Int
+	N *big.Int
+}
+
+type RSAPrivateKey struct {
+	RSAPublicKey
+	D *big.Int
+	P *big.Int
+	Q *big.Int
+}
+
+// Textbook RSA with the default e = 3, which is what the attacks in this set want
+func GenerateRSAKey(bits int) RSAPrivateKey {
+	key, err := GenerateRSAKeyWithExponent(bits, RSA_DEFAULT_E)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return key
+}
+
+/*
+	Textbook RSA key generation:
+		- pick primes p and q, n = p * q
+		- et = (p - 1) * (q - 1)
+		- d = invmod(e, et), start over if e and et aren't coprime
+	e has to be odd and at least 3, otherwise et (always even) never is.
+*/
+func GenerateRSAKeyWithExponent(bits int, e int64) (RSAPrivateKey, error) {
+	if e < 3 || e%2 == 0 {
+		return RSAPrivateKey{}, fmt.Errorf("invalid public exponent %d", e)
+	}
+
+	E := big.NewInt(e)
+	one := big.NewInt(1)
+
+	for {
+		p := GeneratePrime(bits / 2)
+		q := GeneratePrime(bits - bits/2)
+
+		if p.Cmp(q) == 0 {
+			continue
+		}
+
+		et := new(big.Int).Mul(new(big.Int).Sub(p, one), new(big.Int).Sub(q, one))
+
+		d, err := InvMod(E, et)
+		if err != nil {
+			continue
+		}
+
+		return RSAPrivateKey{
+			RSAPublicKey: RSAPublicKey{E: E, N: new(big.Int).Mul(p, q)},
+			D:            d,
+			P:            p,
+			Q:            q,
+		}, nil
+	}
+}
+
+// c = m^e mod n
+func (key RSAPublicKey) Encrypt(m *big.Int) *big.Int {
+	return new(big.Int).Exp(m, key.E, key.N)
+}
+
+// m = c^d mod n
+func (key RSAPrivateKey) Decrypt(c *big.Int) *big.Int {
+	return new(big.Int).Exp(c, key.D, key.N)
+}
+
+// Treats the bytes as a big-endian number. No padding, so the message has to be smaller than n
+func (key RSAPublicKey) EncryptBytes(message []byte) ([]byte, error) {
+	m := new(big.Int).SetBytes(message)
+
+	if m.Cmp(key.N) >= 0 {
+		return nil, errors.New("message too long for key size")
+	}
+
+	return key.Encrypt(m).Bytes(), nil
+}
+
+// Leading zero bytes of the original message don't survive the round trip
+func (key RSAPrivateKey) DecryptBytes(cipherText []byte) []byte {
+	return key.Decrypt(new(big.Int).SetBytes(cipherText)).Bytes()
+}
+
+/*
+	Integer n-th root with Newton's method:
+		- starts above the root and steps down with r = ((n - 1) * r + x / r^(n-1)) / n
+		- stops once r stops shrinking, which leaves floor(x^(1/n))
+	Returns the floor of the root and whether it was exact.
+*/
+func NthRoot(x *big.Int, n int) (*big.Int, bool) {
+	if x.Sign() < 0 || n < 1 {
+		log.Fatalf("can't take root %d of %v", n, x)
+	}
+
+	if x.Sign() == 0 || n == 1 {
+		return new(big.Int).Set(x), true
+	}
+
+	N := big.NewInt(int64(n))
+	nMinusOne := big.NewInt(int64(n - 1))
+
+	// 2^ceil(bits / n) is always >= the root
+	root := new(big.Int).Lsh(big.NewInt(1), uint((x.BitLen()+n-1)/n))
+
+	for {
+		next := new(big.Int).Exp(root, nMinusOne, nil)
+		next.Div(x, next)
+		next.Add(next, new(big.Int).Mul(nMinusOne, root))
+		next.Div(next, N)
+
+		if next.Cmp(root) >= 0 {
+			break
+		}
+
+		root = next
+	}
+
+	exact := new(big.Int).Exp(root, N, nil).Cmp(x) == 0
+
+	return root, exact
+}
+
+func CubeRoot(x *big.Int) (*big.Int, bool) {
+	return NthRoot(x, 3)
+}
+
+// Random big.Int in [min, max], same idea as GenerateRandomInt in set 2
+func GenerateRandomBigIntInRange(min *big.Int, max *big.Int) *big.Int {
+	maxExclusive := new(big.Int).Sub(max, min)
+	maxExclusive.Add(maxExclusive, big.NewInt(1))
+
+	n := GenerateRandomBigInt(maxExclusive)
+
+	return n.Add(n, min)
+}
+
+/*
+	"Decrypt once" server (challenge 41):
+		- Decrypts any ciphertext it hasn't seen before
+		- Remembers a hash of each ciphertext and when it was decrypted
+		- Refuses repeats until "window" has passed (a window of 0 means forever)
+*/
+type RSADecryptionServer struct {
+	key    RSAPrivateKey
+	window time.Duration
+	seen   map[[sha256.Size]byte]time.Time
+	now    func() time.Time
+}
+
+func NewRSADecryptionServer(key RSAPrivateKey, window time.Duration) *RSADecryptionServer {
+	return &RSADecryptionServer{
+		key:    key,
+		window: window,
+		seen:   make(map[[sha256.Size]byte]time.Time),
+		now:    time.Now,
+	}
+}
+
+func (server *RSADecryptionServer) PublicKey() RSAPublicKey {
+	return server.key.RSAPublicKey
+}
+
+func (server *RSADecryptionServer) Decrypt(cipherText []byte) ([]byte, error) {
+	// c and c + n decrypt to the same thing, so hash the reduced value
+	c := new(big.Int).SetBytes(cipherText)
+	c.Mod(c, server.key.N)
+
+	hash := sha256.Sum256(c.Bytes())
+	now := server.now()
+
+	if decryptedAt, ok := server.seen[hash]; ok {
+		if server.window == 0 || now.Sub(decryptedAt) < server.window {
+			return nil, fmt.Errorf("ciphertext already decrypted at %v", decryptedAt.Format(time.RFC3339))
+		}
+	}
+
+	server.seen[hash] = now
+
+	return server.key.Decrypt(c).Bytes(), nil
+}
+
+/*
+	Unpadded message recovery:
+		- Pick a random S with 1 < S < N
+		- C' = (S^e mod N) * C mod N is a brand new ciphertext, so the server decrypts it
+		- P' = C'^d = S * P mod N
+		- P = P' * invmod(S, N) mod N
+*/
+func RecoverUnpaddedMessage(server *RSADecryptionServer, cipherText []byte) ([]byte, error) {
+	key := server.PublicKey()
+	c := new(big.Int).SetBytes(cipherText)
+
+	var S, sInverse *big.Int
+	for sInverse == nil {
+		S = GenerateRandomBigIntInRange(big.NewInt(2), new(big.Int).Sub(key.N, big.NewInt(1)))
+		sInverse, _ = InvMod(S, key.N)
+	}
+
+	blinded := key.Encrypt(S)
+	blinded.Mul(blinded, c)
+	blinded.Mod(blinded, key.N)
+
+	decrypted, err := server.Decrypt(blinded.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	p := new(big.Int).SetBytes(decrypted)
+	p.Mul(p, sInverse)
+	p.Mod(p, key.N)
+
+	return p.Bytes(), nil
+}
+
+// ASN.1 DigestInfo header for SHA-256, goes right before the hash
+var SHA256_DIGEST_INFO = []byte{
+	0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01,
+	0x65, 0x03, 0x04, 0x02, 0x01, 0x05, 0x00, 0x04, 0x20,
+}
+
+func sha256DigestInfo(message []byte) []byte {
+	hash := sha256.Sum256(message)
+
+	return append(append([]byte{}, SHA256_DIGEST_INFO...), hash[:]...)
+}
+
+func modulusByteLength(key RSAPublicKey) int {
+	return (key.N.BitLen() + 7) / 8
+}
+
+/*
+	PKCS#1 v1.5 signature encoding:
+		- 00 01 FF FF ... FF 00 DigestInfo HASH
+		- As many FF bytes as needed to fill the modulus (at least 8)
+*/
+func PKCS1v15SignatureEncode(message []byte, keyLength int) ([]byte, error) {
+	digestInfo := sha256DigestInfo(message)
+	padLength := keyLength - len(digestInfo) - 3
+
+	if padLength < 8 {
+		return nil, errors.New("key too short for a SHA-256 signature")
+	}
+
+	encoded := []byte{0x00, 0x01}
+	encoded = append(encoded, bytes.Repeat([]byte{0xff}, padLength)...)
+	encoded = append(encoded, 0x00)
+
+	return append(encoded, digestInfo...), nil
+}
+
+func SignPKCS1v15(key RSAPrivateKey, message []byte) ([]byte, error) {
+	keyLength := modulusByteLength(key.RSAPublicKey)
+
+	encoded, err := PKCS1v15SignatureEncode(message, keyLength)
+	if err != nil {
+		return nil, err
+	}
+
+	signature := key.Decrypt(new(big.Int).SetBytes(encoded))
+
+	return signature.FillBytes(make([]byte, keyLength)), nil
+}
+
+// sig^e mod n as a block the size of the modulus
+func openSignature(key RSAPublicKey, signature []byte) ([]byte, bool) {
+	s := new(big.Int).SetBytes(signature)
+	if s.Cmp(key.N) >= 0 {
+		return nil, false
+	}
+
+	return key.Encrypt(s).FillBytes(make([]byte, modulusByteLength(key))), true
+}
+
+// Rebuilds the whole expected block and compares every byte
+func VerifyPKCS1v15Strict(key RSAPublicKey, message []byte, signature []byte) bool {
+	block, ok := openSignature(key, signature)
+	if !ok {
+		return false
+	}
+
+	expected, err := PKCS1v15SignatureEncode(message, len(block))
+	if err != nil {
+		return false
+	}
+
+	return bytes.Equal(block, expected)
+}
+
+/*
+	The broken verifier:
+		- checks 00 01, skips over FF bytes until it finds 00
+		- checks the DigestInfo and the hash right after it
+		- never checks that the hash is at the end of the block, so anything can follow it
+*/
+func VerifyPKCS1v15Sloppy(key RSAPublicKey, message []byte, signature []byte) bool {
+	block, ok := openSignature(key, signature)
+	if !ok || block[0] != 0x00 || block[1] != 0x01 {
+		return false
+	}
+
+	i := 2
+	for i < len(block) && block[i] == 0xff {
+		i++
+	}
+
+	if i == 2 || i == len(block) || block[i] != 0x00 {
+		return false
+	}
+
+	return bytes.HasPrefix(block[i+1:], sha256DigestInfo(message))
+}
+
+/*
+	Bleichenbacher's e=3 signature forgery (challenge 42):
+		- Build 00 01 FF 00 DigestInfo HASH followed by garbage, the garbage fills the rest of the block
+		- lo is the block with all-zero garbage, hi is the block with all-FF garbage
+		- Any cube between lo and hi is accepted by the sloppy verifier, so take the cube root of lo rounded up
+		- Only works when the garbage is big enough to cover the gap between consecutive cubes (2048 bit keys with SHA-256)
+*/
+func ForgePKCS1v15Signature(key RSAPublicKey, message []byte) ([]byte, error) {
+	if key.E.Cmp(big.NewInt(3)) != 0 {
+		return nil, errors.New("forgery needs e = 3")
+	}
+
+	keyLength := modulusByteLength(key)
+	prefix := append([]byte{0x00, 0x01, 0xff, 0x00}, sha256DigestInfo(message)...)
+
+	if len(prefix) >= keyLength {
+		return nil, errors.New("key too short to forge a signature")
+	}
+
+	garbageLength := keyLength - len(prefix)
+
+	lo := new(big.Int).SetBytes(append(append([]byte{}, prefix...), make([]byte, garbageLength)...))
+	hi := new(big.Int).SetBytes(append(append([]byte{}, prefix...), bytes.Repeat([]byte{0xff}, garbageLength)...))
+
+	root, exact := CubeRoot(lo)
+	if !exact {
+		root.Add(root, big.NewInt(1))
+	}
+
+	if new(big.Int).Exp(root, big.NewInt(3), nil).Cmp(hi) > 0 {
+		return nil, errors.New("no cube fits in the garbage, key too short")
+	}
+
+	return root.FillBytes(make([]byte, keyLength)), nil
+}
+
+// Domain parameters from challenge 43
+const (
+	DSA_P_HEX = "800000000000000089e1855218a0e7dac38136ffafa72eda7" +
+		"859f2171e25e65eac698c1702578b07dc2a1076da241c76c6" +
+		"2d374d8389ea5aeffd3226a0530cc565f3bf6b50929139ebe" +
+		"ac04f48c3c84afb796d61e5a4f9a8fda812ab59494232c7d2" +
+		"b4deb50aa18ee9e132bfa85ac4374d7f9091abc3d015efc87" +
+		"1a584471bb1"
+	DSA_Q_HEX = "f4f47f05794b256174bba6e9b396a7707e563c5b"
+	DSA_G_HEX = "5958c9d3898b224b12672c0b98e06c60df923cb8bc999d119" +
+		"458fef538b8fa4046c8db53039db620c094c9fa077ef389b5" +
+		"322a559946a71903f990f1f7e0e025e2d7f7cf494aff1a047" +
+		"0f5b64c36b625a097f1651fe775323556fe00b3608c887892" +
+		"878480e99041be601a62166ca6894bdd41a7054ec89f756ba" +
+		"9fc95302291"
+)
+
+func parseHexInt(hexString string) *big.Int {
+	n, ok := new(big.Int).SetString(hexString, 16)
+	if !ok {
+		log.Fatalf("unable to parse hex number %q", hexString)
+	}
+
+	return n
+}
+
+type DSAParams struct {
+	P *big.Int
+	Q *big.Int
+	G *big.Int
+}
+
+func DefaultDSAParams() DSAParams {
+	return DSAParams{P: parseHexInt(DSA_P_HEX), Q: parseHexInt(DSA_Q_HEX), G: parseHexInt(DSA_G_HEX)}
+}
+
+type DSAKey struct {
+	DSAParams
+	X *big.Int
+	Y *big.Int
+}
+
+type DSASignature struct {
+	R *big.Int
+	S *big.Int
+}
+
+// x is random in [1, q - 1], y = g^x mod p
+func GenerateDSAKey(params DSAParams) DSAKey {
+	x := GenerateRandomBigIntInRange(big.NewInt(1), new(big.Int).Sub(params.Q, big.NewInt(1)))
+
+	return DSAKey{DSAParams: params, X: x, Y: new(big.Int).Exp(params.G, x, params.P)}
+}
+
+// H(m) is SHA-1 of the message, read as a number
+func DSAHash(message []byte) *big.Int {
+	hash := sha1.Sum(message)
+
+	return new(big.Int).SetBytes(hash[:])
+}
+
+/*
+	DSA signing with a caller supplied nonce:
+		- r = (g^k mod p) mod q
+		- s = k^-1 * (H(m) + x * r) mod q
+	Returns an error if r or s comes out 0, the caller should pick another k.
+*/
+func (key DSAKey) SignWithNonce(message []byte, k *big.Int) (DSASignature, error) {
+	r := new(big.Int).Exp(key.G, k, key.P)
+	r.Mod(r, key.Q)
+
+	kInverse, err := InvMod(k, key.Q)
+	if err != nil {
+		return DSASignature{}, err
+	}
+
+	s := new(big.Int).Mul(key.X, r)
+	s.Add(s, DSAHash(message))
+	s.Mul(s, kInverse)
+	s.Mod(s, key.Q)
+
+	if r.Sign() == 0 || s.Sign() == 0 {
+		return DSASignature{}, errors.New("bad nonce, r or s is 0")
+	}
+
+	return DSASignature{R: r, S: s}, nil
+}
+
+func (key DSAKey) Sign(message []byte) DSASignature {
+	for {
+		k := GenerateRandomBigIntInRange(big.NewInt(1), new(big.Int).Sub(key.Q, big.NewInt(1)))
+
+		signature, err := key.SignWithNonce(message, k)
+		if err == nil {
+			return signature
+		}
+	}
+}
+
+// Which checks a verifier performs. The zero value is a careful verifier
+type DSAVerifierOptions struct {
+	// Trust whatever g comes with the parameters instead of requiring 1 < g < p and g^q = 1 mod p
+	SkipDomainChecks bool
+	// Don't require 0 < r < q and 0 < s < q
+	SkipRangeChecks bool
+}
+
+// 1 < g < p and g has order q
+func ValidateDSAParams(params DSAParams) error {
+	one := big.NewInt(1)
+
+	if params.G.Cmp(one) <= 0 || params.G.Cmp(params.P) >= 0 {
+		return errors.New("g must be between 1 and p")
+	}
+
+	if new(big.Int).Exp(params.G, params.Q, params.P).Cmp(one) != 0 {
+		return errors.New("g doesn't generate a subgroup of order q")
+	}
+
+	return nil
+}
+
+func VerifyDSA(params DSAParams, y *big.Int, message []byte, signature DSASignature) bool {
+	return VerifyDSAWithOptions(params, y, message, signature, DSAVerifierOptions{})
+}
+
+/*
+	DSA verification:
+		- reject unless 0 < r < q and 0 < s < q
+		- reject parameters with a bad g
+		- w = s^-1 mod q, u1 = H(m) * w mod q, u2 = r * w mod q
+		- v = (g^u1 * y^u2 mod p) mod q, valid when v == r
+*/
+func VerifyDSAWithOptions(params DSAParams, y *big.Int, message []byte, signature DSASignature, options DSAVerifierOptions) bool {
+	r, s := signature.R, signature.S
+
+	if !options.SkipRangeChecks {
+		if r.Sign() <= 0 || r.Cmp(params.Q) >= 0 || s.Sign() <= 0 || s.Cmp(params.Q) >= 0 {
+			return false
+		}
+	}
+
+	if !options.SkipDomainChecks && ValidateDSAParams(params) != nil {
+		return false
+	}
+
+	w, err := InvMod(s, params.Q)
+	if err != nil {
+		return false
+	}
+
+	u1 := new(big.Int).Mul(DSAHash(message), w)
+	u1.Mod(u1, params.Q)
+	u2 := new(big.Int).Mul(r, w)
+	u2.Mod(u2, params.Q)
+
+	v := new(big.Int).Exp(params.G, u1, params.P)
+	v.Mul(v, new(big.Int).Exp(y, u2, params.P))
+	v.Mod(v, params.P)
+	v.Mod(v, params.Q)
+
+	return v.Cmp(r) == 0
+}
+
+// x = (s * k - H(m)) / r mod q
+func RecoverDSAKeyFromNonce(q *big.Int, hash *big.Int, signature DSASignature, k *big.Int) (*big.Int, error) {
+	rInverse, err := InvMod(signature.R, q)
+	if err != nil {
+		return nil, err
+	}
+
+	x := new(big.Int).Mul(signature.S, k)
+	x.Sub(x, hash)
+	x.Mul(x, rInverse)
+
+	return x.Mod(x, q), nil
+}
+
+/*
+	Weak nonce key recovery (challenge 43):
+		- Walks k through [min, max], keeping g^k mod p up to date with one multiplication per step
+		- When (g^k mod p) mod q == r, that k is the nonce (or r happens to collide)
+		- Recovers x from k and only accepts it when g^x mod p == y
+*/
+func RecoverDSAKeyFromNonceRange(params DSAParams, y *big.Int, message []byte, signature DSASignature, min int64, max int64) (*big.Int, bool) {
+	hash := DSAHash(message)
+
+	gToTheK := new(big.Int).Exp(params.G, big.NewInt(min), params.P)
+	r := new(big.Int)
+
+	for k := min; k <= max; k++ {
+		if r.Mod(gToTheK, params.Q).Cmp(signature.R) == 0 {
+			x, err := RecoverDSAKeyFromNonce(params.Q, hash, signature, big.NewInt(k))
+
+			if err == nil && new(big.Int).Exp(params.G, x, params.P).Cmp(y) == 0 {
+				return x, true
+			}
+		}
+
+		gToTheK.Mul(gToTheK, params.G)
+		gToTheK.Mod(gToTheK, params.P)
+	}
+
+	return nil, false
+}
+
+// SHA-1 of the lowercase hex form of x, the way challenge 43 identifies the key
+func DSAKeyFingerprint(x *big.Int) string {
+	hash := sha1.Sum([]byte(x.Text(16)))
+
+	return hex.EncodeToString(hash[:])
+}
+
+// One signed message from a corpus
+type DSARecord struct {
+	Message   string
+	Hash      *big.Int
+	Signature DSASignature
+}
+
+// Accepts decimal, or hex with a 0x prefix
+func parseSignatureInt(value string) (*big.Int, error) {
+	value = strings.TrimSpace(value)
+	base := 10
+
+	if strings.HasPrefix(value, "0x") || strings.HasPrefix(value, "0X") {
+		value, base = value[2:], 16
+	}
+
+	n, ok := new(big.Int).SetString(value, base)
+	if !ok {
+		return nil, fmt.Errorf("invalid number %q", value)
+	}
+
+	return n, nil
+}
+
+/*
+	Challenge 44 format, four lines per record:
+		msg: <message>
+		s: <decimal>
+		r: <decimal>
+		m: <hex SHA-1 of message>
+	The m line closes the record. Without one the hash is computed from msg.
+*/
+func ParseChallenge44Records(reader io.Reader) ([]DSARecord, error) {
+	scanner := bufio.NewScanner(reader)
+	records := make([]DSARecord, 0)
+
+	var current DSARecord
+	hasMessage := false
+
+	finish := func() {
+		if current.Hash == nil {
+			current.Hash = DSAHash([]byte(current.Message))
+		}
+
+		records = append(records, current)
+		current = DSARecord{}
+		hasMessage = false
+	}
+
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		field, value, ok := strings.Cut(line, ": ")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"field: value\"", lineNumber)
+		}
+
+		var err error
+
+		switch field {
+		case "msg":
+			if hasMessage {
+				finish()
+			}
+
+			current.Message = value
+			hasMessage = true
+		case "s":
+			current.Signature.S, err = parseSignatureInt(value)
+		case "r":
+			current.Signature.R, err = parseSignatureInt(value)
+		case "m":
+			current.Hash, err = parseSignatureInt("0x" + strings.TrimSpace(value))
+			if err == nil {
+				finish()
+			}
+		default:
+			err = fmt.Errorf("unknown field %q", field)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if hasMessage {
+		finish()
+	}
+
+	for i, record := range records {
+		if record.Signature.R == nil || record.Signature.S == nil {
+			return nil, fmt.Errorf("record %d: missing r or s", i)
+		}
+	}
+
+	return records, nil
+}
+
+/*
+	Generic CSV export:
+		- first row is a header with "message", "r" and "s" columns in any order
+		- an optional "hash" column holds the hex SHA-1 of the message, otherwise it gets computed
+		- r and s are decimal, or hex with a 0x prefix
+*/
+func ParseDSACSVRecords(reader io.Reader) ([]DSARecord, error) {
+	rows, err := csv.NewReader(reader).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) == 0 {
+		return nil, errors.New("missing header row")
+	}
+
+	columns := make(map[string]int)
+	for i, name := range rows[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	for _, required := range []string{"message", "r", "s"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("missing %q column", required)
+		}
+	}
+
+	records := make([]DSARecord, 0, len(rows)-1)
+
+	for i, row := range rows[1:] {
+		record := DSARecord{Message: row[columns["message"]]}
+
+		if record.Signature.R, err = parseSignatureInt(row[columns["r"]]); err != nil {
+			return nil, fmt.Errorf("row %d: %v", i+2, err)
+		}
+
+		if record.Signature.S, err = parseSignatureInt(row[columns["s"]]); err != nil {
+			return nil, fmt.Errorf("row %d: %v", i+2, err)
+		}
+
+		if column, ok := columns["hash"]; ok && strings.TrimSpace(row[column]) != "" {
+			if record.Hash, err = parseSignatureInt("0x" + strings.TrimSpace(row[column])); err != nil {
+				return nil, fmt.Errorf("row %d: %v", i+2, err)
+			}
+		} else {
+			record.Hash = DSAHash([]byte(record.Message))
+		}
+
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+type RepeatedNonceFinding struct {
+	R        *big.Int
+	K        *big.Int
+	X        *big.Int
+	Verified bool // g^x == y, only checked when a public key was given
+	Messages []string
+}
+
+/*
+	Repeated nonce analysis (challenge 44):
+		- Signatures made with the same k share r, so group the records by r
+		- For two signatures in a group: k = (m1 - m2) / (s1 - s2) mod q
+		- Then x = (s * k - m) / r mod q, checked against y when y isn't nil
+	Groups where no pair gives a usable k (identical s values) are skipped.
+*/
+func FindRepeatedDSANonces(params DSAParams, y *big.Int, records []DSARecord) []RepeatedNonceFinding {
+	groups := make(map[string][]DSARecord)
+	order := make([]string, 0)
+
+	for _, record := range records {
+		key := record.Signature.R.String()
+
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+
+		groups[key] = append(groups[key], record)
+	}
+
+	findings := make([]RepeatedNonceFinding, 0)
+
+	for _, key := range order {
+		group := groups[key]
+		if len(group) < 2 {
+			continue
+		}
+
+		finding, ok := recoverFromSharedNonce(params, y, group)
+		if ok {
+			findings = append(findings, finding)
+		}
+	}
+
+	return findings
+}
+
+func recoverFromSharedNonce(params DSAParams, y *big.Int, group []DSARecord) (RepeatedNonceFinding, bool) {
+	q := params.Q
+
+	for i := 0; i < len(group); i++ {
+		for j := i + 1; j < len(group); j++ {
+			first, second := group[i], group[j]
+
+			sDiff := new(big.Int).Sub(first.Signature.S, second.Signature.S)
+
+			sDiffInverse, err := InvMod(sDiff, q)
+			if err != nil {
+				continue
+			}
+
+			k := new(big.Int).Sub(first.Hash, second.Hash)
+			k.Mul(k, sDiffInverse)
+			k.Mod(k, q)
+
+			x, err := RecoverDSAKeyFromNonce(q, first.Hash, first.Signature, k)
+			if err != nil {
+				continue
+			}
+
+			finding := RepeatedNonceFinding{R: first.Signature.R, K: k, X: x}
+
+			if y != nil {
+				finding.Verified = new(big.Int).Exp(params.G, x, params.P).Cmp(y) == 0
+
+				if !finding.Verified {
+					continue
+				}
+			}
+
+			for _, record := range group {
+				finding.Messages = append(finding.Messages, record.Message)
+			}
+
+			return finding, true
+		}
+	}
+
+	return RepeatedNonceFinding{}, false
+}
+
+// Human readable summary of the findings
+func WriteRepeatedNonceReport(writer io.Writer, findings []RepeatedNonceFinding) error {
+	if len(findings) == 0 {
+		_, err := fmt.Fprintln(writer, "no repeated nonces found")
+		return err
+	}
+
+	for _, finding := range findings {
+		_, err := fmt.Fprintf(writer, "r = %v\n  k = %v\n  x = %v (fingerprint %v, verified: %v)\n  messages:\n",
+			finding.R, finding.K, finding.X.Text(16), DSAKeyFingerprint(finding.X), finding.Verified)
+		if err != nil {
+			return err
+		}
+
+		for _, message := range finding.Messages {
+			if _, err := fmt.Fprintf(writer, "    %q\n", message); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// The DSA parameters with g swapped out, the way an attacker hands them to a verifier
+func TamperedDSAParams(params DSAParams, g *big.Int) DSAParams {
+	return DSAParams{P: params.P, Q: params.Q, G: g}
+}
+
+/*
+	g = 0 (challenge 45):
+		- Every g^k is 0, so a signer produces r = 0
+		- A verifier that doesn't check r computes v = (0^u1 * y^u2 mod p) mod q = 0 = r
+		- So (0, anything) verifies for every message and every y
+*/
+func ForgeDSASignatureZeroG(params DSAParams) DSASignature {
+	s := GenerateRandomBigIntInRange(big.NewInt(1), new(big.Int).Sub(params.Q, big.NewInt(1)))
+
+	return DSASignature{R: big.NewInt(0), S: s}
+}
+
+/*
+	g = p + 1 magic signature (challenge 45):
+		- g = 1 mod p, so g^u1 disappears from verification and v = y^(r * w) mod p mod q
+		- Pick any z, r = (y^z mod p) mod q, s = r / z mod q
+		- Then r * w = z, v = (y^z mod p) mod q = r for every message
+*/
+func ForgeDSAMagicSignature(params DSAParams, y *big.Int) DSASignature {
+	for {
+		z := GenerateRandomBigIntInRange(big.NewInt(1), new(big.Int).Sub(params.Q, big.NewInt(1)))
+
+		r := new(big.Int).Exp(y, z, params.P)
+		r.Mod(r, params.Q)
+
+		zInverse, err := InvMod(z, params.Q)
+		if err != nil || r.Sign() == 0 {
+			continue
+		}
+
+		s := new(big.Int).Mul(r, zInverse)
+		s.Mod(s, params.Q)
+
+		return DSASignature{R: r, S: s}
+	}
+}
+
+// Decrypts anything but only says whether the plaintext is even (challenge 46)
+type RSAParityOracle struct {
+	key RSAPrivateKey
+}
+
+func NewRSAParityOracle(key RSAPrivateKey) *RSAParityOracle {
+	return &RSAParityOracle{key: key}
+}
+
+func (oracle *RSAParityOracle) PublicKey() RSAPublicKey {
+	return oracle.key.RSAPublicKey
+}
+
+func (oracle *RSAParityOracle) IsEven(cipherText []byte) bool {
+	return oracle.key.Decrypt(new(big.Int).SetBytes(cipherText)).Bit(0) == 0
+}
+
+/*
+	Parity oracle attack:
+		- Multiplying the ciphertext by 2^e doubles the plaintext mod n
+		- n is odd, so 2 * m mod n is even exactly when 2 * m didn't wrap, i.e. m < n / 2
+		- Keep doubling: after i steps the plaintext is in [j * n / 2^i, (j + 1) * n / 2^i)
+		  and each answer picks the lower (j = 2j) or upper (j = 2j + 1) half
+		- After log2(n) steps the interval is narrower than 1 and its top is the plaintext
+	If progress isn't nil, the current upper bound gets written to it after every step.
+*/
+func RSAParityOracleAttack(oracle *RSAParityOracle, cipherText []byte, progress io.Writer) ([]byte, error) {
+	key := oracle.PublicKey()
+	steps := key.N.BitLen()
+
+	doubler := key.Encrypt(big.NewInt(2))
+	c := new(big.Int).SetBytes(cipherText)
+	j := big.NewInt(0)
+
+	upperBound := func(step int) *big.Int {
+		upper := new(big.Int).Add(j, big.NewInt(1))
+		upper.Mul(upper, key.N)
+
+		return upper.Rsh(upper, uint(step))
+	}
+
+	for i := 1; i <= steps; i++ {
+		c.Mul(c, doubler)
+		c.Mod(c, key.N)
+
+		j.Lsh(j, 1)
+		if !oracle.IsEven(c.Bytes()) {
+			j.Add(j, big.NewInt(1))
+		}
+
+		if progress != nil {
+			if _, err := fmt.Fprintf(progress, "%q\n", upperBound(i).Bytes()); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return upperBound(steps).Bytes(), nil
+}
+
+/*
+	PKCS#1 v1.5 encryption padding:
+		- 00 02 PS 00 M where PS is at least 8 random non-zero bytes
+		- the block is exactly the size of the modulus
+*/
+func PKCS1v15Pad(message []byte, keyLength int) ([]byte, error) {
+	padLength := keyLength - len(message) - 3
+
+	if padLength < 8 {
+		return nil, errors.New("message too long for key size")
+	}
+
+	padding := make([]byte, 0, padLength)
+	for len(padding) < padLength {
+		for _, bite := range GenerateRandomBytes(padLength - len(padding)) {
+			if bite != 0 {
+				padding = append(padding, bite)
+			}
+		}
+	}
+
+	block := append([]byte{0x00, 0x02}, padding...)
+	block = append(block, 0x00)
+
+	return append(block, message...), nil
+}
+
+func PKCS1v15Unpad(block []byte) ([]byte, error) {
+	if len(block) < 11 || block[0] != 0x00 || block[1] != 0x02 {
+		return nil, errors.New("block doesn't start with 00 02")
+	}
+
+	separator := bytes.IndexByte(block[2:], 0x00)
+	if separator < 8 {
+		return nil, errors.New("invalid padding string")
+	}
+
+	return block[2+separator+1:], nil
+}
+
+func EncryptPKCS1v15(key RSAPublicKey, message []byte) ([]byte, error) {
+	keyLength := modulusByteLength(key)
+
+	block, err := PKCS1v15Pad(message, keyLength)
+	if err != nil {
+		return nil, err
+	}
+
+	return key.Encrypt(new(big.Int).SetBytes(block)).FillBytes(make([]byte, keyLength)), nil
+}
+
+/*
+	Padding oracle (challenges 47 and 48):
+		- Decrypts and only says whether the block starts with 00 02
+		- Decrypts with the CRT since the attack makes a lot of queries
+		- Calls counts every query
+*/
+type PKCS1v15PaddingOracle struct {
+	key   RSAPrivateKey
+	dp    *big.Int
+	dq    *big.Int
+	qInv  *big.Int
+	Calls int
+}
+
+func NewPKCS1v15PaddingOracle(key RSAPrivateKey) *PKCS1v15PaddingOracle {
+	one := big.NewInt(1)
+
+	qInv, err := InvMod(key.Q, key.P)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return &PKCS1v15PaddingOracle{
+		key:  key,
+		dp:   new(big.Int).Mod(key.D, new(big.Int).Sub(key.P, one)),
+		dq:   new(big.Int).Mod(key.D, new(big.Int).Sub(key.Q, one)),
+		qInv: qInv,
+	}
+}
+
+func (oracle *PKCS1v15PaddingOracle) PublicKey() RSAPublicKey {
+	return oracle.key.RSAPublicKey
+}
+
+func (oracle *PKCS1v15PaddingOracle) Conforming(cipherText []byte) bool {
+	oracle.Calls++
+
+	c := new(big.Int).SetBytes(cipherText)
+	key := oracle.key
+
+	// m = m2 + q * (qInv * (m1 - m2) mod p)
+	m1 := new(big.Int).Exp(c, oracle.dp, key.P)
+	m2 := new(big.Int).Exp(c, oracle.dq, key.Q)
+
+	h := new(big.Int).Sub(m1, m2)
+	h.Mul(h, oracle.qInv)
+	h.Mod(h, key.P)
+
+	m := h.Mul(h, key.Q)
+	m.Add(m, m2)
+
+	// 00 02 on a k byte block means 2B <= m < 3B
+	keyLength := modulusByteLength(key.RSAPublicKey)
+
+	return m.BitLen() <= 8*(keyLength-1) && m.Rsh(m, uint(8*(keyLength-2))).Cmp(big.NewInt(2)) == 0
+}
+
+type bigInterval struct {
+	a *big.Int
+	b *big.Int
+}
+
+func ceilDiv(x *big.Int, y *big.Int) *big.Int {
+	q, m := new(big.Int).DivMod(x, y, new(big.Int))
+	if m.Sign() != 0 {
+		q.Add(q, big.NewInt(1))
+	}
+
+	return q
+}
+
+func floorDiv(x *big.Int, y *big.Int) *big.Int {
+	return new(big.Int).Div(x, y)
+}
+
+// Adds [a, b] to the set, merging it with anything it overlaps
+func addInterval(intervals []bigInterval, a *big.Int, b *big.Int) []bigInterval {
+	for i, interval := range intervals {
+		if a.Cmp(interval.b) <= 0 && b.Cmp(interval.a) >= 0 {
+			if a.Cmp(interval.a) > 0 {
+				a = interval.a
+			}
+
+			if b.Cmp(interval.b) < 0 {
+				b = interval.b
+			}
+
+			rest := append(append([]bigInterval{}, intervals[:i]...), intervals[i+1:]...)
+
+			return addInterval(rest, a, b)
+		}
+	}
+
+	return append(intervals, bigInterval{a: a, b: b})
+}
+
+/*
+	Bleichenbacher 1998:
+		- Step 1: blind c until it's conforming (c0 = c * s0^e), skipped when c already is
+		- Every conforming c0 * s^e means 2B <= m * s mod n < 3B, which narrows down where m can be
+		- Step 2a: first s from n / 3B upwards
+		- Step 2b: with more than one interval left, keep searching upwards from the last s
+		- Step 2c: with one interval [a, b] left, search small r and s around 2 * (b * s - 2B) / n
+		- Step 3: intersect every interval with every possible wrap count r for the new s
+		- Step 4: once the interval is a single number, m = a * s0^-1 mod n
+	Returns the unpadded message.
+*/
+func BleichenbacherAttack(oracle *PKCS1v15PaddingOracle, cipherText []byte) ([]byte, error) {
+	key := oracle.PublicKey()
+	n := key.N
+	keyLength := modulusByteLength(key)
+	one := big.NewInt(1)
+
+	B := new(big.Int).Lsh(one, uint(8*(keyLength-2)))
+	twoB := new(big.Int).Mul(B, big.NewInt(2))
+	threeB := new(big.Int).Mul(B, big.NewInt(3))
+
+	c := new(big.Int).SetBytes(cipherText)
+
+	conforming := func(s *big.Int) bool {
+		blinded := key.Encrypt(s)
+		blinded.Mul(blinded, c)
+		blinded.Mod(blinded, n)
+
+		return oracle.Conforming(blinded.Bytes())
+	}
+
+	// Step 1
+	s0 := big.NewInt(1)
+	for !conforming(s0) {
+		s0 = GenerateRandomBigIntInRange(big.NewInt(2), new(big.Int).Sub(n, one))
+	}
+
+	c = key.Encrypt(s0)
+	c.Mul(c, new(big.Int).SetBytes(cipherText))
+	c.Mod(c, n)
+
+	intervals := []bigInterval{{a: new(big.Int).Set(twoB), b: new(big.Int).Sub(threeB, one)}}
+	var s *big.Int
+
+	for i := 1; ; i++ {
+		switch {
+		case i == 1:
+			// Step 2a
+			s = ceilDiv(n, threeB)
+			for !conforming(s) {
+				s.Add(s, one)
+			}
+		case len(intervals) > 1:
+			// Step 2b
+			s = new(big.Int).Add(s, one)
+			for !conforming(s) {
+				s.Add(s, one)
+			}
+		default:
+			// Step 2c
+			a, b := intervals[0].a, intervals[0].b
+
+			r := new(big.Int).Mul(b, s)
+			r.Sub(r, twoB)
+			r.Mul(r, big.NewInt(2))
+			r = ceilDiv(r, n)
+
+			found := false
+			for !found {
+				rn := new(big.Int).Mul(r, n)
+
+				low := ceilDiv(new(big.Int).Add(twoB, rn), b)
+				high := floorDiv(new(big.Int).Add(threeB, rn), a)
+
+				for candidate := low; candidate.Cmp(high) <= 0; candidate.Add(candidate, one) {
+					if conforming(candidate) {
+						s, found = candidate, true
+						break
+					}
+				}
+
+				r.Add(r, one)
+			}
+		}
+
+		// Step 3
+		next := make([]bigInterval, 0)
+
+		for _, interval := range intervals {
+			rLow := new(big.Int).Mul(interval.a, s)
+			rLow.Sub(rLow, threeB)
+			rLow.Add(rLow, one)
+			rLow = ceilDiv(rLow, n)
+
+			rHigh := new(big.Int).Mul(interval.b, s)
+			rHigh.Sub(rHigh, twoB)
+			rHigh = floorDiv(rHigh, n)
+
+			for r := rLow; r.Cmp(rHigh) <= 0; r = new(big.Int).Add(r, one) {
+				rn := new(big.Int).Mul(r, n)
+
+				a := ceilDiv(new(big.Int).Add(twoB, rn), s)
+				if a.Cmp(interval.a) < 0 {
+					a = interval.a
+				}
+
+				b := floorDiv(new(big.Int).Add(new(big.Int).Sub(threeB, one), rn), s)
+				if b.Cmp(interval.b) > 0 {
+					b = interval.b
+				}
+
+				if a.Cmp(b) <= 0 {
+					next = addInterval(next, a, b)
+				}
+			}
+		}
+
+		if len(next) == 0 {
+			return nil, errors.New("no intervals left, the oracle isn't behaving like a padding oracle")
+		}
+
+		intervals = next
+
+		// Step 4
+		if len(intervals) == 1 && intervals[0].a.Cmp(intervals[0].b) == 0 {
+			s0Inverse, err := InvMod(s0, n)
+			if err != nil {
+				return nil, err
+			}
+
+			m := new(big.Int).Mul(intervals[0].a, s0Inverse)
+			m.Mod(m, n)
+
+			return PKCS1v15Unpad(m.FillBytes(make([]byte, keyLength)))
+		}
+	}
+}
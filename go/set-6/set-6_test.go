@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRSADecryptionServerRefusesRepeats(t *testing.T) {
+	server := NewRSADecryptionServer(GenerateRSAKey(1024), time.Hour)
+	message := []byte(`{time: 1356304276, social: '555-55-5555'}`)
+
+	cipherText, err := server.PublicKey().EncryptBytes(message)
+	assert.Nil(t, err)
+
+	plainText, err := server.Decrypt(cipherText)
+	assert.Nil(t, err)
+	assert.Equal(t, message, plainText)
+
+	_, err = server.Decrypt(cipherText)
+	assert.NotNil(t, err)
+
+	// once the window is over it can be decrypted again
+	server.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+
+	plainText, err = server.Decrypt(cipherText)
+	assert.Nil(t, err)
+	assert.Equal(t, message, plainText)
+}
+
+func TestUnpaddedMessageRecovery(t *testing.T) {
+	/*
+		- Someone else already had the server decrypt the ciphertext
+		- Blind it with S^e, have the server decrypt that, then divide S back out
+	*/
+	server := NewRSADecryptionServer(GenerateRSAKey(1024), 0)
+	message := []byte(`{time: 1356304276, social: '555-55-5555'}`)
+
+	cipherText, err := server.PublicKey().EncryptBytes(message)
+	assert.Nil(t, err)
+
+	_, err = server.Decrypt(cipherText)
+	assert.Nil(t, err)
+
+	_, err = server.Decrypt(cipherText)
+	assert.NotNil(t, err)
+
+	recovered, err := RecoverUnpaddedMessage(server, cipherText)
+
+	fmt.Printf("recovered: %s\n", recovered)
+
+	assert.Nil(t, err)
+	assert.Equal(t, message, recovered)
+}
+
+func TestPKCS1v15Signatures(t *testing.T) {
+	key := GenerateRSAKey(1024)
+	message := []byte("hi mom")
+
+	signature, err := SignPKCS1v15(key, message)
+	assert.Nil(t, err)
+
+	assert.True(t, VerifyPKCS1v15Strict(key.RSAPublicKey, message, signature))
+	assert.True(t, VerifyPKCS1v15Sloppy(key.RSAPublicKey, message, signature))
+
+	assert.False(t, VerifyPKCS1v15Strict(key.RSAPublicKey, []byte("hi dad"), signature))
+	assert.False(t, VerifyPKCS1v15Sloppy(key.RSAPublicKey, []byte("hi dad"), signature))
+}
+
+func TestBleichenbacherSignatureForgery(t *testing.T) {
+	/*
+		- e = 3 and a verifier that doesn't check what comes after the hash
+		- Put the hash near the top of the block and cube root it, the garbage at the bottom soaks up the error
+	*/
+	key := GenerateRSAKey(2048)
+	message := []byte("hi mom")
+
+	forged, err := ForgePKCS1v15Signature(key.RSAPublicKey, message)
+
+	assert.Nil(t, err)
+	assert.True(t, VerifyPKCS1v15Sloppy(key.RSAPublicKey, message, forged))
+	assert.False(t, VerifyPKCS1v15Strict(key.RSAPublicKey, message, forged))
+
+	// 1024 bits doesn't leave enough garbage for a SHA-256 forgery
+	_, err = ForgePKCS1v15Signature(GenerateRSAKey(1024).RSAPublicKey, message)
+
+	assert.NotNil(t, err)
+}
+
+func TestDSASignAndVerify(t *testing.T) {
+	key := GenerateDSAKey(DefaultDSAParams())
+	message := []byte("hi mom")
+
+	signature := key.Sign(message)
+
+	assert.True(t, VerifyDSA(key.DSAParams, key.Y, message, signature))
+	assert.False(t, VerifyDSA(key.DSAParams, key.Y, []byte("hi dad"), signature))
+
+	// a known nonce gives the private key away
+	k := big.NewInt(1234567)
+	signature, err := key.SignWithNonce(message, k)
+	assert.Nil(t, err)
+
+	x, err := RecoverDSAKeyFromNonce(key.Q, DSAHash(message), signature, k)
+	assert.Nil(t, err)
+	assert.Equal(t, key.X, x)
+}
+
+func TestDSAKeyRecoveryFromWeakNonce(t *testing.T) {
+	/*
+		- The signer's nonce was somewhere in 0..2^16
+		- Try every k, the one that reproduces r gives x
+	*/
+	params := DefaultDSAParams()
+	y := parseHexInt("84ad4719d044495496a3201c8ff484feb45b962e7302e56a392aee4" +
+		"abab3e4bdebf2955b4736012f21a08084056b19bcd7fee56048e004" +
+		"e44984e2f411788efdc837a0d2e5abb7b555039fd243ac01f0fb2ed" +
+		"1dec568280ce678e931868d23eb095fde9d3779191b8c0299d6e07b" +
+		"bb283e6633451e535c45513b2d33c99ea17")
+
+	message := []byte("For those that envy a MC it can be hazardous to your health\n" +
+		"So be friendly, a matter of life and death, just like a etch-a-sketch\n")
+
+	assert.Equal(t, "d2d0714f014a9784047eaeccf956520045c45265", DSAHash(message).Text(16))
+
+	r, _ := new(big.Int).SetString("548099063082341131477253921760299949438196259240", 10)
+	s, _ := new(big.Int).SetString("857042759984254168557880549501802188789837994940", 10)
+
+	x, found := RecoverDSAKeyFromNonceRange(params, y, message, DSASignature{R: r, S: s}, 0, 1<<16)
+
+	assert.True(t, found)
+	assert.Equal(t, "0954edd5e0afe5542a4adf012611a91912a3ec16", DSAKeyFingerprint(x))
+}
+
+// Signs every message, reusing the same nonce for the ones at reusedAt
+func buildDSACorpus(t *testing.T, key DSAKey, messages []string, reusedAt map[int]bool) []DSARecord {
+	reusedK := big.NewInt(987654321)
+	records := make([]DSARecord, 0)
+
+	for i, message := range messages {
+		var signature DSASignature
+
+		if reusedAt[i] {
+			var err error
+			signature, err = key.SignWithNonce([]byte(message), reusedK)
+			assert.Nil(t, err)
+		} else {
+			signature = key.Sign([]byte(message))
+		}
+
+		records = append(records, DSARecord{Message: message, Hash: DSAHash([]byte(message)), Signature: signature})
+	}
+
+	return records
+}
+
+func TestRepeatedDSANonces(t *testing.T) {
+	/*
+		- Same k means same r
+		- Two signatures with the same k: k = (m1 - m2) / (s1 - s2) mod q
+		- Corpus is read back from the challenge 44 format and from CSV
+	*/
+	key := GenerateDSAKey(DefaultDSAParams())
+	messages := []string{
+		"Listen for me, you better listen for me now. ",
+		"Listen for me, you better listen for me now. ",
+		"When me rockin' the microphone me rock on steady, ",
+		"Yes a Daddy me Snow me are de article dan. ",
+		"But in a in an' a out de dance em ",
+	}
+	records := buildDSACorpus(t, key, messages, map[int]bool{1: true, 3: true})
+
+	challenge44 := ""
+	csvExport := "r,s,message\n"
+
+	for _, record := range records {
+		challenge44 += fmt.Sprintf("msg: %s\ns: %v\nr: %v\nm: %x\n",
+			record.Message, record.Signature.S, record.Signature.R, record.Hash)
+		csvExport += fmt.Sprintf("0x%x,%v,\"%s\"\n", record.Signature.R, record.Signature.S, record.Message)
+	}
+
+	fromChallenge44, err := ParseChallenge44Records(strings.NewReader(challenge44))
+	assert.Nil(t, err)
+	assert.Equal(t, records, fromChallenge44)
+
+	fromCSV, err := ParseDSACSVRecords(strings.NewReader(csvExport))
+	assert.Nil(t, err)
+	assert.Equal(t, records, fromCSV)
+
+	findings := FindRepeatedDSANonces(key.DSAParams, key.Y, fromChallenge44)
+
+	assert.Len(t, findings, 1)
+	assert.Equal(t, key.X, findings[0].X)
+	assert.Equal(t, big.NewInt(987654321), findings[0].K)
+	assert.True(t, findings[0].Verified)
+	assert.Equal(t, []string{messages[1], messages[3]}, findings[0].Messages)
+
+	var report strings.Builder
+	assert.Nil(t, WriteRepeatedNonceReport(&report, findings))
+
+	fmt.Println(report.String())
+
+	assert.Contains(t, report.String(), DSAKeyFingerprint(key.X))
+	assert.Contains(t, report.String(), "Yes a Daddy me Snow me are de article dan.")
+
+	// without a public key the finding is reported but not verified
+	findings = FindRepeatedDSANonces(key.DSAParams, nil, fromCSV)
+
+	assert.Len(t, findings, 1)
+	assert.Equal(t, key.X, findings[0].X)
+	assert.False(t, findings[0].Verified)
+}
+
+func TestParseDSARecordErrors(t *testing.T) {
+	_, err := ParseChallenge44Records(strings.NewReader("msg: hi\nr: 12\nm: ab\n"))
+	assert.NotNil(t, err)
+
+	_, err = ParseChallenge44Records(strings.NewReader("msg: hi\ns: nope\n"))
+	assert.NotNil(t, err)
+
+	_, err = ParseDSACSVRecords(strings.NewReader("message,r\nhi,12\n"))
+	assert.NotNil(t, err)
+}
+
+func TestDSAParameterTampering(t *testing.T) {
+	/*
+		- The verifier gets handed parameters with a malicious g
+		- g = 0: r = 0 verifies everything when r isn't range checked
+		- g = p + 1: a magic signature built from y alone verifies everything
+	*/
+	params := DefaultDSAParams()
+	key := GenerateDSAKey(params)
+	messages := [][]byte{[]byte("Hello, world"), []byte("Goodbye, world")}
+
+	lenient := DSAVerifierOptions{SkipDomainChecks: true, SkipRangeChecks: true}
+
+	zeroG := TamperedDSAParams(params, big.NewInt(0))
+
+	// a careful signer refuses to produce r = 0
+	_, err := GenerateDSAKey(zeroG).SignWithNonce(messages[0], big.NewInt(12345))
+	assert.NotNil(t, err)
+
+	zeroSignature := ForgeDSASignatureZeroG(zeroG)
+
+	for _, message := range messages {
+		assert.True(t, VerifyDSAWithOptions(zeroG, key.Y, message, zeroSignature, lenient))
+		assert.False(t, VerifyDSAWithOptions(zeroG, key.Y, message, zeroSignature, DSAVerifierOptions{SkipDomainChecks: true}))
+		assert.False(t, VerifyDSA(zeroG, key.Y, message, zeroSignature))
+	}
+
+	pPlusOne := TamperedDSAParams(params, new(big.Int).Add(params.P, big.NewInt(1)))
+	magicSignature := ForgeDSAMagicSignature(pPlusOne, key.Y)
+
+	for _, message := range messages {
+		assert.True(t, VerifyDSAWithOptions(pPlusOne, key.Y, message, magicSignature, lenient))
+		assert.True(t, VerifyDSAWithOptions(pPlusOne, key.Y, message, magicSignature, DSAVerifierOptions{SkipDomainChecks: true}))
+		assert.False(t, VerifyDSA(pPlusOne, key.Y, message, magicSignature))
+	}
+
+	assert.Nil(t, ValidateDSAParams(params))
+	assert.NotNil(t, ValidateDSAParams(zeroG))
+	assert.NotNil(t, ValidateDSAParams(pPlusOne))
+}
+
+func TestRSAParityOracleAttack(t *testing.T) {
+	/*
+		- Double the plaintext by multiplying the ciphertext by 2^e
+		- Even means it didn't wrap around n, so the plaintext is in the lower half
+		- One bit of the plaintext's position per query
+	*/
+	key, err := GenerateRSAKeyWithExponent(1024, 65537)
+	assert.Nil(t, err)
+
+	oracle := NewRSAParityOracle(key)
+	message, _ := base64.StdEncoding.DecodeString("VGhhdCdzIHdoeSBJIGZvdW5kIHlvdSBkb24ndCBwbGF5IGFyb3VuZCB3aXRoIHRoZSBGdW5reSBDb2xkIE1lZGluYQ==")
+
+	cipherText, err := oracle.PublicKey().EncryptBytes(message)
+	assert.Nil(t, err)
+
+	var progress bytes.Buffer
+	recovered, err := RSAParityOracleAttack(oracle, cipherText, &progress)
+
+	lines := strings.Split(strings.TrimSpace(progress.String()), "\n")
+	fmt.Println(lines[len(lines)-1])
+
+	assert.Nil(t, err)
+	assert.Equal(t, message, recovered)
+	assert.Len(t, lines, oracle.PublicKey().N.BitLen())
+	assert.Equal(t, fmt.Sprintf("%q", message), lines[len(lines)-1])
+
+	// progress is optional
+	recovered, err = RSAParityOracleAttack(oracle, cipherText, nil)
+
+	assert.Nil(t, err)
+	assert.Equal(t, message, recovered)
+}
+
+func TestPKCS1v15Padding(t *testing.T) {
+	block, err := PKCS1v15Pad([]byte("kick it, CC"), 32)
+
+	assert.Nil(t, err)
+	assert.Len(t, block, 32)
+	assert.Equal(t, []byte{0x00, 0x02}, block[:2])
+
+	message, err := PKCS1v15Unpad(block)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("kick it, CC"), message)
+
+	_, err = PKCS1v15Pad(make([]byte, 22), 32)
+	assert.NotNil(t, err)
+}
+
+func TestBleichenbacherPaddingOracleAttack(t *testing.T) {
+	/*
+		- Oracle only says whether a ciphertext decrypts to 00 02 ...
+		- Every conforming s narrows down the range the plaintext can be in
+		- 256 bit modulus (challenge 47) and 768 bit modulus (challenge 48)
+	*/
+	for _, bits := range []int{256, 768} {
+		if bits > 256 && testing.Short() {
+			continue
+		}
+
+		oracle := NewPKCS1v15PaddingOracle(GenerateRSAKey(bits))
+		message := []byte("kick it, CC")
+
+		cipherText, err := EncryptPKCS1v15(oracle.PublicKey(), message)
+		assert.Nil(t, err)
+		assert.True(t, oracle.Conforming(cipherText))
+
+		recovered, err := BleichenbacherAttack(oracle, cipherText)
+
+		fmt.Printf("%d bits: recovered %q with %d oracle calls\n", bits, recovered, oracle.Calls)
+
+		assert.Nil(t, err)
+		assert.Equal(t, message, recovered)
+	}
+}
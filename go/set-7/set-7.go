@@ -0,0 +1,1574 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"math/bits"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const BLOCK_SIZE = 16
+
+func GenerateRandomBytes(byteLength int) []byte {
+	token := make([]byte, byteLength)
+
+	_, err := rand.Read(token)
+	if err != nil {
+		log.Fatalf("error generating random key: %v", err)
+	}
+
+	return token
+}
+
+func xor(prevBlock []byte, currBlock []byte) []byte {
+	var xordBytes []byte = make([]byte, BLOCK_SIZE)
+
+	for i := 0; i < BLOCK_SIZE; i++ {
+		xordBytes[i] = prevBlock[i] ^ currBlock[i]
+	}
+
+	return xordBytes
+}
+
+// Pads to a multiple of BLOCK_SIZE, each padding byte is the amount of padding added
+func pkcs7Pad(data []byte) []byte {
+	padding := BLOCK_SIZE - len(data)%BLOCK_SIZE
+	padded := make([]byte, len(data), len(data)+padding)
+	copy(padded, data)
+
+	for i := 0; i < padding; i++ {
+		padded = append(padded, byte(padding))
+	}
+
+	return padded
+}
+
+/*
+	Same chaining as encryptAESCBC in set 2, with the IV passed in:
+		- XOR prev cipherText, starting with IV, with current plaintext block
+		- encrypt the result
+	data has to be a multiple of BLOCK_SIZE already.
+*/
+func encryptAESCBC(data []byte, key []byte, iv []byte) []byte {
+	cipher, err := aes.NewCipher(key)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	encryptedBytes := make([]byte, len(data))
+	amtOfBlocks := len(data) / BLOCK_SIZE
+
+	previousBlock := iv
+
+	for i := 0; i < amtOfBlocks; i++ {
+		start := i * BLOCK_SIZE
+		end := (i + 1) * BLOCK_SIZE
+
+		xordBytes := xor(previousBlock, data[start:end])
+		cipher.Encrypt(encryptedBytes[start:end], xordBytes)
+
+		previousBlock = encryptedBytes[start:end]
+	}
+
+	return encryptedBytes
+}
+
+// CBC-MAC: pad, CBC encrypt and keep only the last ciphertext block
+func CBCMAC(message []byte, key []byte, iv []byte) []byte {
+	cipherText := encryptAESCBC(pkcs7Pad(message), key, iv)
+
+	return cipherText[len(cipherText)-BLOCK_SIZE:]
+}
+
+type Transfer struct {
+	From   string
+	To     string
+	Amount int
+}
+
+type Transaction struct {
+	To     string
+	Amount int
+}
+
+type TransactionList struct {
+	From         string
+	Transactions []Transaction
+}
+
+func parseQuery(message string) map[string]string {
+	fields := make(map[string]string)
+
+	for _, pair := range strings.Split(message, "&") {
+		key, value, ok := strings.Cut(pair, "=")
+		if ok {
+			fields[key] = value
+		}
+	}
+
+	return fields
+}
+
+/*
+	Web client and API server share a MAC key (challenge 49):
+		- The client only signs requests from the logged in account
+		- v1 requests are message || IV || MAC, the IV comes from the client
+		- v2 requests are message || MAC with a fixed zero IV
+*/
+type TransactionClient struct {
+	key       []byte
+	AccountID string
+}
+
+func NewTransactionClient(key []byte, accountID string) TransactionClient {
+	return TransactionClient{key: key, AccountID: accountID}
+}
+
+// "from=#{from_id}&to=#{to_id}&amount=#{amount}" || IV || MAC
+func (client TransactionClient) Transfer(to string, amount int) []byte {
+	message := []byte(fmt.Sprintf("from=%s&to=%s&amount=%d", client.AccountID, to, amount))
+	iv := GenerateRandomBytes(BLOCK_SIZE)
+
+	request := append(message, iv...)
+
+	return append(request, CBCMAC(message, client.key, iv)...)
+}
+
+// "from=#{from_id}&tx_list=#{to:amount(;to:amount)*}" || MAC
+func (client TransactionClient) TransactionList(transactions []Transaction) []byte {
+	list := make([]string, 0, len(transactions))
+	for _, transaction := range transactions {
+		list = append(list, fmt.Sprintf("%s:%d", transaction.To, transaction.Amount))
+	}
+
+	message := []byte(fmt.Sprintf("from=%s&tx_list=%s", client.AccountID, strings.Join(list, ";")))
+
+	return append(message, CBCMAC(message, client.key, make([]byte, BLOCK_SIZE))...)
+}
+
+type TransactionServer struct {
+	key []byte
+}
+
+func NewTransactionServer(key []byte) TransactionServer {
+	return TransactionServer{key: key}
+}
+
+func (server TransactionServer) ProcessTransfer(request []byte) (Transfer, error) {
+	if len(request) < 2*BLOCK_SIZE {
+		return Transfer{}, errors.New("request too short")
+	}
+
+	message := request[:len(request)-2*BLOCK_SIZE]
+	iv := request[len(request)-2*BLOCK_SIZE : len(request)-BLOCK_SIZE]
+	mac := request[len(request)-BLOCK_SIZE:]
+
+	if !hmac.Equal(mac, CBCMAC(message, server.key, iv)) {
+		return Transfer{}, errors.New("invalid MAC")
+	}
+
+	fields := parseQuery(string(message))
+
+	amount, err := strconv.Atoi(fields["amount"])
+	if err != nil {
+		return Transfer{}, fmt.Errorf("invalid amount: %v", err)
+	}
+
+	return Transfer{From: fields["from"], To: fields["to"], Amount: amount}, nil
+}
+
+/*
+	Verifies the MAC, then parses the transaction list.
+	Entries that don't parse as to:amount get skipped instead of failing the whole request,
+	which is the loose parsing that lets a length extension through.
+*/
+func (server TransactionServer) ProcessTransactionList(request []byte) (TransactionList, error) {
+	if len(request) < BLOCK_SIZE {
+		return TransactionList{}, errors.New("request too short")
+	}
+
+	message := request[:len(request)-BLOCK_SIZE]
+	mac := request[len(request)-BLOCK_SIZE:]
+
+	if !hmac.Equal(mac, CBCMAC(message, server.key, make([]byte, BLOCK_SIZE))) {
+		return TransactionList{}, errors.New("invalid MAC")
+	}
+
+	fields := parseQuery(string(message))
+	list := TransactionList{From: fields["from"]}
+
+	for _, entry := range strings.Split(fields["tx_list"], ";") {
+		to, amountText, ok := strings.Cut(entry, ":")
+
+		amount, err := strconv.Atoi(amountText)
+		if !ok || err != nil {
+			continue
+		}
+
+		list.Transactions = append(list.Transactions, Transaction{To: to, Amount: amount})
+	}
+
+	return list, nil
+}
+
+/*
+	IV forgery on v1 requests:
+		- The attacker gets the client to sign "from=ATTACKER&to=ATTACKER&amount=N" for their own account
+		- The from field sits in the first block, which gets XORed with the IV before encryption
+		- Swap the from field for the victim (same length ID) and flip the same bits in the IV,
+		  the first block going into AES is unchanged so the MAC still checks out
+*/
+func ForgeTransferIV(request []byte, victimID string) ([]byte, error) {
+	if len(request) < 2*BLOCK_SIZE {
+		return nil, errors.New("request too short")
+	}
+
+	message := request[:len(request)-2*BLOCK_SIZE]
+	iv := request[len(request)-2*BLOCK_SIZE : len(request)-BLOCK_SIZE]
+	mac := request[len(request)-BLOCK_SIZE:]
+
+	if len(message) < BLOCK_SIZE {
+		return nil, errors.New("message is shorter than a block")
+	}
+
+	fields := parseQuery(string(message))
+	attackerID := fields["from"]
+
+	if len(attackerID) != len(victimID) {
+		return nil, errors.New("victim and attacker IDs need to be the same length")
+	}
+
+	forged := []byte(strings.Replace(string(message), "from="+attackerID, "from="+victimID, 1))
+
+	if len("from="+victimID) > BLOCK_SIZE {
+		return nil, errors.New("from field doesn't fit in the first block")
+	}
+
+	forgedIV := xor(iv, xor(message[:BLOCK_SIZE], forged[:BLOCK_SIZE]))
+
+	result := append(forged, forgedIV...)
+
+	return append(result, mac...), nil
+}
+
+/*
+	Length extension on v2 requests (fixed IV):
+		- captured is the victim's message M with MAC t
+		- attackerRequest is the attacker's own signed message M' with MAC t'
+		- pad(M) || (M'[0] XOR t) || M'[1:] puts the CBC state back to exactly where M' starts,
+		  so t' is a valid MAC for the whole thing
+		- The victim's request now ends with whatever the attacker put after M's first block
+	Fails when the glue block contains '&', that would cut the tx_list short. Capture another request and retry.
+*/
+func ForgeTransactionListExtension(captured []byte, attackerRequest []byte) ([]byte, error) {
+	if len(captured) < BLOCK_SIZE || len(attackerRequest) < 2*BLOCK_SIZE {
+		return nil, errors.New("request too short")
+	}
+
+	message := captured[:len(captured)-BLOCK_SIZE]
+	mac := captured[len(captured)-BLOCK_SIZE:]
+
+	extension := attackerRequest[:len(attackerRequest)-BLOCK_SIZE]
+	extensionMAC := attackerRequest[len(attackerRequest)-BLOCK_SIZE:]
+
+	glue := xor(extension[:BLOCK_SIZE], mac)
+	if strings.Contains(string(glue), "&") {
+		return nil, errors.New("glue block contains '&'")
+	}
+
+	forged := pkcs7Pad(message)
+	forged = append(forged, glue...)
+	forged = append(forged, extension[BLOCK_SIZE:]...)
+
+	return append(forged, extensionMAC...), nil
+}
+
+// Fixed key and zero IV from challenge 50
+const CBC_MAC_HASH_KEY = "YELLOW SUBMARINE"
+
+// CBC-MAC used as a "hash" for JavaScript snippets. Anyone who knows the key can forge it
+func CBCMACHash(snippet []byte) []byte {
+	return CBCMAC(snippet, []byte(CBC_MAC_HASH_KEY), make([]byte, BLOCK_SIZE))
+}
+
+func isPrintable(data []byte) bool {
+	for _, bite := range data {
+		if bite < 0x20 || bite > 0x7e {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Fills a block with printable characters, a base 95 encoding of counter
+func printableFiller(counter uint64) []byte {
+	filler := bytes.Repeat([]byte{' '}, BLOCK_SIZE)
+
+	for i := 0; counter > 0 && i < BLOCK_SIZE; i++ {
+		filler[i] = byte(' ' + counter%95)
+		counter /= 95
+	}
+
+	return filler
+}
+
+/*
+	CBC-MAC hash collision (challenge 50):
+		- Start with the new snippet, open a "//" comment and pad it out to a block boundary
+		- Add a filler block of printable characters, this is what we search over
+		- The glue block is (CBC state so far) XOR original[0], which puts the CBC state where
+		  the original's first block leaves it, so original[1:] finishes with the same MAC
+		- Everything after "//" is inside the comment as long as the glue has no line break
+		- Tries up to maxAttempts fillers looking for a fully printable glue block,
+		  otherwise settles for the first glue block without a line break
+	Returns the forged snippet and whether it's fully printable.
+*/
+func ForgeCBCMACHashCollision(target []byte, original []byte, maxAttempts int) ([]byte, bool, error) {
+	if len(original) < BLOCK_SIZE {
+		return nil, false, errors.New("original snippet has to be at least one block long")
+	}
+
+	if bytes.ContainsAny(target, "\n\r") {
+		return nil, false, errors.New("target snippet has to be a single line")
+	}
+
+	key := []byte(CBC_MAC_HASH_KEY)
+
+	prefix := append(append([]byte{}, target...), "//"...)
+	for len(prefix)%BLOCK_SIZE != 0 {
+		prefix = append(prefix, ' ')
+	}
+
+	prefixCipherText := encryptAESCBC(prefix, key, make([]byte, BLOCK_SIZE))
+	state := prefixCipherText[len(prefixCipherText)-BLOCK_SIZE:]
+
+	cipher, err := aes.NewCipher(key)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var fallback []byte
+	afterFiller := make([]byte, BLOCK_SIZE)
+
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		filler := printableFiller(uint64(attempt))
+
+		cipher.Encrypt(afterFiller, xor(state, filler))
+		glue := xor(afterFiller, original[:BLOCK_SIZE])
+
+		printable := isPrintable(glue)
+		if !printable && (fallback != nil || bytes.ContainsAny(glue, "\n\r")) {
+			continue
+		}
+
+		forged := append(append([]byte{}, prefix...), filler...)
+		forged = append(forged, glue...)
+		forged = append(forged, original[BLOCK_SIZE:]...)
+
+		if printable {
+			return forged, true, nil
+		}
+
+		fallback = forged
+	}
+
+	if fallback == nil {
+		return nil, false, errors.New("every glue block had a line break")
+	}
+
+	return fallback, false, nil
+}
+
+/*
+	AES in CTR mode:
+		- keystream block i is AES(key, nonce || little endian counter i)
+		- XOR the keystream with the data, encrypting and decrypting are the same thing
+*/
+func aesCTR(data []byte, key []byte, nonce uint64) []byte {
+	cipher, err := aes.NewCipher(key)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	output := make([]byte, len(data))
+	counterBlock := make([]byte, BLOCK_SIZE)
+	keystream := make([]byte, BLOCK_SIZE)
+
+	binary.LittleEndian.PutUint64(counterBlock[:8], nonce)
+
+	for start := 0; start < len(data); start += BLOCK_SIZE {
+		binary.LittleEndian.PutUint64(counterBlock[8:], uint64(start/BLOCK_SIZE))
+		cipher.Encrypt(keystream, counterBlock)
+
+		for i := start; i < len(data) && i < start+BLOCK_SIZE; i++ {
+			output[i] = data[i] ^ keystream[i-start]
+		}
+	}
+
+	return output
+}
+
+func zlibCompress(data []byte) []byte {
+	var compressed bytes.Buffer
+
+	writer := zlib.NewWriter(&compressed)
+	if _, err := writer.Write(data); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := writer.Close(); err != nil {
+		log.Fatal(err)
+	}
+
+	return compressed.Bytes()
+}
+
+/*
+	Compression oracle (challenge 51):
+		- Formats an HTTP request with the secret session cookie and the attacker's body
+		- Compresses it, then encrypts it with a fresh key (and nonce or IV) every time
+		- Mode is "CTR" (ciphertext length = compressed length) or "CBC" (rounded up to whole blocks)
+		- Only the length of the ciphertext gets back to the attacker
+*/
+type CompressionOracle struct {
+	sessionID string
+	Mode      string
+}
+
+func NewCompressionOracle(sessionID string, mode string) CompressionOracle {
+	return CompressionOracle{sessionID: sessionID, Mode: mode}
+}
+
+func (oracle CompressionOracle) formatRequest(payload []byte) []byte {
+	request := fmt.Sprintf("POST / HTTP/1.1\nHost: hapless.com\nCookie: sessionid=%s\nContent-Length: %d\n",
+		oracle.sessionID, len(payload))
+
+	return append([]byte(request), payload...)
+}
+
+func (oracle CompressionOracle) Length(payload []byte) int {
+	compressed := zlibCompress(oracle.formatRequest(payload))
+	key := GenerateRandomBytes(16)
+
+	switch oracle.Mode {
+	case "CBC":
+		return len(encryptAESCBC(pkcs7Pad(compressed), key, GenerateRandomBytes(BLOCK_SIZE)))
+	case "CTR":
+		return len(aesCTR(compressed, key, binary.LittleEndian.Uint64(GenerateRandomBytes(8))))
+	}
+
+	panic(fmt.Sprintf("compression oracle mode has to be \"CTR\" or \"CBC\", got %q", oracle.Mode))
+}
+
+const BASE64_ALPHABET = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/="
+
+// Random bytes that never show up in the request, so they don't compress against anything
+func incompressiblePadding(length int) []byte {
+	padding := make([]byte, 0, length)
+
+	for len(padding) < length {
+		for _, bite := range GenerateRandomBytes(length) {
+			if bite >= 0x80 && len(padding) < length {
+				padding = append(padding, bite)
+			}
+		}
+	}
+
+	return padding
+}
+
+/*
+	Compression side channel attack:
+		- Send known + guess as the body. The right guess repeats more of the cookie and compresses better
+		- The compressor doesn't look for matches in the last few bytes of its input,
+		  so the guess is followed by a few incompressible bytes
+		- Lengths tie a lot because compression works in bits and CBC rounds up to blocks,
+		  so put a random amount of incompressible padding in front and retry until exactly
+		  one guess comes out shorter than all the others
+		- The cookie ends when "\n" is the winning guess
+	Returns the recovered cookie value (without the known prefix).
+*/
+func CompressionAttack(oracle CompressionOracle, knownPrefix string, maxLength int) (string, error) {
+	alphabet := BASE64_ALPHABET + "\n"
+	recovered := ""
+
+	for len(recovered) < maxLength {
+		next, err := guessNextCookieByte(oracle, knownPrefix+recovered, alphabet)
+		if err != nil {
+			return recovered, err
+		}
+
+		if next == '\n' {
+			return recovered, nil
+		}
+
+		recovered += string(next)
+	}
+
+	return recovered, fmt.Errorf("cookie longer than %d characters", maxLength)
+}
+
+func guessNextCookieByte(oracle CompressionOracle, known string, alphabet string) (byte, error) {
+	const maxTrials = 256
+
+	for trial := 0; trial < maxTrials; trial++ {
+		padding := incompressiblePadding(trial % (2 * BLOCK_SIZE))
+		suffix := incompressiblePadding(4)
+
+		best, bestLength, ties := byte(0), 0, 0
+
+		for i := 0; i < len(alphabet); i++ {
+			payload := append(append([]byte{}, padding...), known...)
+			payload = append(payload, alphabet[i])
+			payload = append(payload, suffix...)
+
+			length := oracle.Length(payload)
+
+			switch {
+			case ties == 0 || length < bestLength:
+				best, bestLength, ties = alphabet[i], length, 1
+			case length == bestLength:
+				ties++
+			}
+		}
+
+		if ties == 1 {
+			return best, nil
+		}
+	}
+
+	return 0, fmt.Errorf("no unique best guess after %q", known)
+}
+
+/*
+	Cheap Merkle-Damgard hash (challenge 52):
+		- State is "Bits" bits (16 to 32) held in a uint32
+		- Compression: AES-encrypt the message block with the state (zero padded) as the key,
+		  then keep the top "Bits" bits
+		- Messages get MD strengthening: 0x80, zeros, then the bit length as 8 bytes
+		- Calls counts compression function calls
+*/
+type MDHash struct {
+	Bits    int
+	Initial uint32
+	calls   atomic.Int64
+}
+
+func NewMDHash(bits int, initial uint32) *MDHash {
+	if bits < 16 || bits > 32 {
+		log.Fatalf("hash size has to be 16 to 32 bits, got %d", bits)
+	}
+
+	return &MDHash{Bits: bits, Initial: initial & mdMask(bits)}
+}
+
+func mdMask(bits int) uint32 {
+	return uint32((uint64(1) << bits) - 1)
+}
+
+func (hash *MDHash) Calls() int64 {
+	return hash.calls.Load()
+}
+
+func (hash *MDHash) Compress(state uint32, block []byte) uint32 {
+	hash.calls.Add(1)
+
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint32(key, state)
+
+	cipher, err := aes.NewCipher(key)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	output := make([]byte, BLOCK_SIZE)
+	cipher.Encrypt(output, block)
+
+	return binary.BigEndian.Uint32(output) >> (32 - hash.Bits)
+}
+
+// Runs the compression function over whole blocks, no padding
+func (hash *MDHash) Iterate(state uint32, message []byte) uint32 {
+	for start := 0; start+BLOCK_SIZE <= len(message); start += BLOCK_SIZE {
+		state = hash.Compress(state, message[start:start+BLOCK_SIZE])
+	}
+
+	return state
+}
+
+// 0x80, zeros, then the message length in bits as 8 bytes, out to a whole number of blocks
+func MDPadding(messageLength int) []byte {
+	padding := []byte{0x80}
+	for (messageLength+len(padding)+8)%BLOCK_SIZE != 0 {
+		padding = append(padding, 0x00)
+	}
+
+	return binary.BigEndian.AppendUint64(padding, uint64(messageLength)*8)
+}
+
+func (hash *MDHash) Sum(message []byte) uint32 {
+	padded := append(append([]byte{}, message...), MDPadding(len(message))...)
+
+	return hash.Iterate(hash.Initial, padded)
+}
+
+/*
+	Birthday search for one block collision from a given state:
+		- hash random blocks until two of them land on the same next state
+		- takes around 2^(Bits / 2) compression calls
+	Returns both blocks and the state they collide on.
+*/
+func (hash *MDHash) FindCollision(state uint32) ([]byte, []byte, uint32) {
+	seen := make(map[uint32][]byte)
+
+	for {
+		block := GenerateRandomBytes(BLOCK_SIZE)
+		next := hash.Compress(state, block)
+
+		if other, ok := seen[next]; ok && !bytes.Equal(other, block) {
+			return other, block, next
+		}
+
+		seen[next] = block
+	}
+}
+
+/*
+	Joux multicollision:
+		- One collision per block: Pairs[i] are two blocks that both take the state from level i to level i + 1
+		- Picking either block at every level gives 2^n messages of n blocks with the same final state
+*/
+type Multicollision struct {
+	Pairs  [][2][]byte
+	States []uint32 // States[0] is the starting state, States[i + 1] the state after level i
+}
+
+func (hash *MDHash) NewMulticollision(state uint32) *Multicollision {
+	return &Multicollision{States: []uint32{state}}
+}
+
+// Adds another level with one more birthday search, doubling the number of colliding messages
+func (multicollision *Multicollision) Extend(hash *MDHash) {
+	a, b, next := hash.FindCollision(multicollision.States[len(multicollision.States)-1])
+
+	multicollision.Pairs = append(multicollision.Pairs, [2][]byte{a, b})
+	multicollision.States = append(multicollision.States, next)
+}
+
+func (hash *MDHash) FindMulticollision(state uint32, n int) *Multicollision {
+	multicollision := hash.NewMulticollision(state)
+
+	for i := 0; i < n; i++ {
+		multicollision.Extend(hash)
+	}
+
+	return multicollision
+}
+
+// Message number "index": bit i of index picks the block at level i
+func (multicollision *Multicollision) Message(index uint64) []byte {
+	message := make([]byte, 0, len(multicollision.Pairs)*BLOCK_SIZE)
+
+	for i, pair := range multicollision.Pairs {
+		message = append(message, pair[(index>>i)&1]...)
+	}
+
+	return message
+}
+
+func (multicollision *Multicollision) Count() uint64 {
+	return uint64(1) << len(multicollision.Pairs)
+}
+
+type CascadeCollision struct {
+	A           []byte
+	B           []byte
+	CheapCalls  int64
+	CostlyCalls int64
+}
+
+/*
+	Breaking h(m) = f(m) || g(m) where f is cheap and g is more expensive:
+		- Build a multicollision in f with 2^(g.Bits / 2) messages
+		- Hash all of them with g (walking the tree so shared prefixes are only hashed once)
+		  and look for a g collision, odds are decent that there is one
+		- If not, add one more level to the f multicollision and look again
+	The pair collides in f and g, so in h, for a total cost way under 2^(f.Bits / 2 + g.Bits / 2).
+*/
+func BreakCascade(cheap *MDHash, costly *MDHash) CascadeCollision {
+	cheapStart, costlyStart := cheap.Calls(), costly.Calls()
+
+	multicollision := cheap.FindMulticollision(cheap.Initial, costly.Bits/2)
+
+	for {
+		// both messages are the same length, so the padding block keeps the collision
+		seen := make(map[uint32]uint64)
+		var found *CascadeCollision
+
+		var walk func(level int, state uint32, index uint64)
+		walk = func(level int, state uint32, index uint64) {
+			if found != nil {
+				return
+			}
+
+			if level == len(multicollision.Pairs) {
+				final := costly.Compress(state, MDPadding(level * BLOCK_SIZE))
+
+				if other, ok := seen[final]; ok {
+					found = &CascadeCollision{A: multicollision.Message(other), B: multicollision.Message(index)}
+					return
+				}
+
+				seen[final] = index
+				return
+			}
+
+			for choice := uint64(0); choice < 2; choice++ {
+				next := costly.Compress(state, multicollision.Pairs[level][choice])
+				walk(level+1, next, index|choice<<level)
+			}
+		}
+
+		walk(0, costly.Initial, 0)
+
+		if found != nil {
+			found.CheapCalls = cheap.Calls() - cheapStart
+			found.CostlyCalls = costly.Calls() - costlyStart
+
+			return *found
+		}
+
+		multicollision.Extend(cheap)
+	}
+}
+
+/*
+	Birthday search across two starting states:
+		- hash random blocks from both states, alternating, until an output from one side
+		  matches an output from the other
+	Returns the block for state a, the block for state b and the state they both reach.
+*/
+func (hash *MDHash) findCrossCollision(a uint32, b uint32) ([]byte, []byte, uint32) {
+	fromA := make(map[uint32][]byte)
+	fromB := make(map[uint32][]byte)
+
+	for {
+		blockA := GenerateRandomBytes(BLOCK_SIZE)
+		nextA := hash.Compress(a, blockA)
+
+		if blockB, ok := fromB[nextA]; ok {
+			return blockA, blockB, nextA
+		}
+		fromA[nextA] = blockA
+
+		blockB := GenerateRandomBytes(BLOCK_SIZE)
+		nextB := hash.Compress(b, blockB)
+
+		if blockA, ok := fromA[nextB]; ok {
+			return blockA, blockB, nextB
+		}
+		fromB[nextB] = blockB
+	}
+}
+
+/*
+	Expandable message (Kelsey-Schneier):
+		- Level i is a collision between one block and 2^(k - 1 - i) + 1 blocks
+		- Picking the short or long side at every level gives any length from k to k + 2^k - 1 blocks,
+		  all ending on the same state
+*/
+type ExpandableMessage struct {
+	short [][]byte
+	long  [][]byte
+	State uint32
+}
+
+func (hash *MDHash) BuildExpandableMessage(state uint32, k int) *ExpandableMessage {
+	expandable := &ExpandableMessage{}
+	dummy := make([]byte, BLOCK_SIZE)
+
+	for i := 0; i < k; i++ {
+		dummyCount := 1 << (k - 1 - i)
+
+		dummies := bytes.Repeat(dummy, dummyCount)
+		afterDummies := hash.Iterate(state, dummies)
+
+		short, last, next := hash.findCrossCollision(state, afterDummies)
+
+		expandable.short = append(expandable.short, short)
+		expandable.long = append(expandable.long, append(dummies, last...))
+		state = next
+	}
+
+	expandable.State = state
+
+	return expandable
+}
+
+func (expandable *ExpandableMessage) MinBlocks() int {
+	return len(expandable.short)
+}
+
+func (expandable *ExpandableMessage) MaxBlocks() int {
+	return len(expandable.short) + (1 << len(expandable.short)) - 1
+}
+
+// A message of exactly "blocks" blocks that ends on expandable.State
+func (expandable *ExpandableMessage) Produce(blocks int) ([]byte, error) {
+	if blocks < expandable.MinBlocks() || blocks > expandable.MaxBlocks() {
+		return nil, fmt.Errorf("can only produce %d to %d blocks", expandable.MinBlocks(), expandable.MaxBlocks())
+	}
+
+	k := len(expandable.short)
+	extra := blocks - k
+	message := make([]byte, 0, blocks*BLOCK_SIZE)
+
+	// level i adds 2^(k - 1 - i) extra blocks when the long side is picked
+	for i := 0; i < k; i++ {
+		if (extra>>(k-1-i))&1 == 1 {
+			message = append(message, expandable.long[i]...)
+		} else {
+			message = append(message, expandable.short[i]...)
+		}
+	}
+
+	return message, nil
+}
+
+type SecondPreimage struct {
+	Message []byte
+	Blocks  int
+	Calls   int64
+}
+
+/*
+	Second preimage for a long message:
+		- Message has 2^k blocks, remember the state after every block
+		- Build a k level expandable message
+		- Find a bridge block that takes the expandable message's final state to one of the
+		  intermediate states, after block j (somewhere past block k)
+		- Forged = expandable message of j - 1 blocks || bridge || the rest of the original
+		- Same length means same padding, so the same hash
+	Costs around k * 2^(Bits / 2) + 2^Bits / 2^k calls instead of 2^Bits.
+*/
+func (hash *MDHash) FindSecondPreimage(message []byte) (SecondPreimage, error) {
+	start := hash.Calls()
+	blocks := len(message) / BLOCK_SIZE
+
+	k := 0
+	for (1 << (k + 1)) <= blocks {
+		k++
+	}
+
+	if k < 1 {
+		return SecondPreimage{}, errors.New("message is too short")
+	}
+
+	// state after j blocks, for every j that an expandable message + bridge can reach
+	intermediate := make(map[uint32]int)
+	state := hash.Initial
+
+	for j := 1; j <= blocks; j++ {
+		state = hash.Compress(state, message[(j-1)*BLOCK_SIZE:j*BLOCK_SIZE])
+
+		if j > k {
+			intermediate[state] = j
+		}
+	}
+
+	expandable := hash.BuildExpandableMessage(hash.Initial, k)
+
+	for {
+		bridge := GenerateRandomBytes(BLOCK_SIZE)
+
+		j, ok := intermediate[hash.Compress(expandable.State, bridge)]
+		if !ok || j-1 > expandable.MaxBlocks() {
+			continue
+		}
+
+		prefix, err := expandable.Produce(j - 1)
+		if err != nil {
+			return SecondPreimage{}, err
+		}
+
+		forged := append(prefix, bridge...)
+		forged = append(forged, message[j*BLOCK_SIZE:]...)
+
+		if bytes.Equal(forged, message) {
+			continue
+		}
+
+		return SecondPreimage{Message: forged, Blocks: len(forged) / BLOCK_SIZE, Calls: hash.Calls() - start}, nil
+	}
+}
+
+type diamondNode struct {
+	state  uint32
+	block  []byte // takes this node's state to its parent's
+	parent int
+}
+
+/*
+	Diamond structure (challenge 54):
+		- Level 0 has 2^k random starting states
+		- Pair them up and find blocks that take both states in a pair to the same next state,
+		  which halves the number of states every level
+		- After k levels everything funnels into a single root state
+		- Every pair's collision search is independent, so they run across "workers" goroutines
+*/
+type Diamond struct {
+	hash   *MDHash
+	levels [][]diamondNode
+}
+
+func BuildDiamond(hash *MDHash, k int, workers int) *Diamond {
+	if workers < 1 {
+		workers = 1
+	}
+
+	// distinct leaves, a repeat would waste a whole branch
+	leaves := make([]diamondNode, 0, 1<<k)
+	used := make(map[uint32]bool)
+
+	for len(leaves) < 1<<k {
+		state := binary.BigEndian.Uint32(GenerateRandomBytes(4)) & mdMask(hash.Bits)
+
+		if !used[state] {
+			used[state] = true
+			leaves = append(leaves, diamondNode{state: state})
+		}
+	}
+
+	diamond := &Diamond{hash: hash, levels: [][]diamondNode{leaves}}
+
+	for level := 0; level < k; level++ {
+		current := diamond.levels[level]
+		next := make([]diamondNode, len(current)/2)
+
+		pairs := make(chan int)
+		var wg sync.WaitGroup
+
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+
+				for pair := range pairs {
+					left, right := &current[2*pair], &current[2*pair+1]
+
+					leftBlock, rightBlock, state := hash.findCrossCollision(left.state, right.state)
+
+					left.block, left.parent = leftBlock, pair
+					right.block, right.parent = rightBlock, pair
+					next[pair] = diamondNode{state: state}
+				}
+			}()
+		}
+
+		for pair := range next {
+			pairs <- pair
+		}
+
+		close(pairs)
+		wg.Wait()
+
+		diamond.levels = append(diamond.levels, next)
+	}
+
+	return diamond
+}
+
+func (diamond *Diamond) Root() uint32 {
+	return diamond.levels[len(diamond.levels)-1][0].state
+}
+
+func (diamond *Diamond) Levels() int {
+	return len(diamond.levels) - 1
+}
+
+/*
+	The digest to publish. The final message will be the prefix (prefixBlocks blocks), one linking
+	block and one block per diamond level, and its padding has to be baked into the commitment.
+*/
+func (diamond *Diamond) Commit(prefixBlocks int) uint32 {
+	totalLength := (prefixBlocks + 1 + diamond.Levels()) * BLOCK_SIZE
+
+	return diamond.hash.Iterate(diamond.Root(), MDPadding(totalLength))
+}
+
+/*
+	Herding:
+		- Pad the prefix with spaces out to prefixBlocks blocks and hash it
+		- Search for a linking block that lands on any of the 2^k leaves, split across goroutines
+		- Follow the diamond from that leaf to the root
+	The result hashes to Commit(prefixBlocks).
+*/
+func (diamond *Diamond) Herd(prefix []byte, prefixBlocks int, workers int) ([]byte, error) {
+	if len(prefix) > prefixBlocks*BLOCK_SIZE {
+		return nil, fmt.Errorf("prefix is longer than the %d blocks committed to", prefixBlocks)
+	}
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	message := append([]byte{}, prefix...)
+	message = append(message, bytes.Repeat([]byte{' '}, prefixBlocks*BLOCK_SIZE-len(prefix))...)
+
+	state := diamond.hash.Iterate(diamond.hash.Initial, message)
+
+	leafIndex := make(map[uint32]int)
+	for i, leaf := range diamond.levels[0] {
+		leafIndex[leaf.state] = i
+	}
+
+	type link struct {
+		block []byte
+		leaf  int
+	}
+
+	found := make(chan link, 1)
+	done := make(chan struct{})
+	var once sync.Once
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				block := GenerateRandomBytes(BLOCK_SIZE)
+
+				if leaf, ok := leafIndex[diamond.hash.Compress(state, block)]; ok {
+					once.Do(func() {
+						found <- link{block: block, leaf: leaf}
+						close(done)
+					})
+
+					return
+				}
+			}
+		}()
+	}
+
+	linked := <-found
+	wg.Wait()
+
+	message = append(message, linked.block...)
+
+	node := linked.leaf
+	for level := 0; level < diamond.Levels(); level++ {
+		message = append(message, diamond.levels[level][node].block...)
+		node = diamond.levels[level][node].parent
+	}
+
+	return message, nil
+}
+
+/*
+	MD4 (RFC 1320):
+		- 3 rounds of 16 steps over 16 little endian words
+		- Round 1: F(x, y, z) = (x & y) | (^x & z), shifts 3, 7, 11, 19
+		- Round 2: G(x, y, z) = majority, + 0x5a827999, shifts 3, 5, 9, 13
+		- Round 3: H(x, y, z) = x ^ y ^ z, + 0x6ed9eba1, shifts 3, 9, 11, 15
+*/
+var MD4_INITIAL_STATE = [4]uint32{0x67452301, 0xefcdab89, 0x98badcfe, 0x10325476}
+
+var md4Round1Shifts = [4]int{3, 7, 11, 19}
+var md4Round2Shifts = [4]int{3, 5, 9, 13}
+var md4Round3Shifts = [4]int{3, 9, 11, 15}
+var md4Round2Order = [16]int{0, 4, 8, 12, 1, 5, 9, 13, 2, 6, 10, 14, 3, 7, 11, 15}
+var md4Round3Order = [16]int{0, 8, 4, 12, 2, 10, 6, 14, 1, 9, 5, 13, 3, 11, 7, 15}
+
+func md4F(x, y, z uint32) uint32 { return (x & y) | (^x & z) }
+func md4G(x, y, z uint32) uint32 { return (x & y) | (x & z) | (y & z) }
+func md4H(x, y, z uint32) uint32 { return x ^ y ^ z }
+
+func md4Words(block []byte) [16]uint32 {
+	var words [16]uint32
+
+	for i := range words {
+		words[i] = binary.LittleEndian.Uint32(block[4*i:])
+	}
+
+	return words
+}
+
+/*
+	Runs all 48 steps and returns every intermediate value:
+		- states[0..3] are a0, d0, c0, b0
+		- step t writes states[4 + t], so a_k = states[4k], d_k = states[4k + 1], c_k = states[4k + 2], b_k = states[4k + 3]
+*/
+func md4Steps(state [4]uint32, words [16]uint32) [52]uint32 {
+	var states [52]uint32
+	states[0], states[1], states[2], states[3] = state[0], state[3], state[2], state[1]
+
+	for t := 0; t < 48; t++ {
+		a, b, c, d := states[t], states[t+3], states[t+2], states[t+1]
+
+		var value uint32
+		switch round := t / 16; round {
+		case 0:
+			value = bits.RotateLeft32(a+md4F(b, c, d)+words[t], md4Round1Shifts[t%4])
+		case 1:
+			value = bits.RotateLeft32(a+md4G(b, c, d)+words[md4Round2Order[t%16]]+0x5a827999, md4Round2Shifts[t%4])
+		default:
+			value = bits.RotateLeft32(a+md4H(b, c, d)+words[md4Round3Order[t%16]]+0x6ed9eba1, md4Round3Shifts[t%4])
+		}
+
+		states[t+4] = value
+	}
+
+	return states
+}
+
+func md4Compress(state [4]uint32, words [16]uint32) [4]uint32 {
+	states := md4Steps(state, words)
+
+	return [4]uint32{
+		state[0] + states[48],
+		state[1] + states[51],
+		state[2] + states[50],
+		state[3] + states[49],
+	}
+}
+
+// Same as MDPadding but MD4 stores the length little endian
+func md4Padding(messageLength int) []byte {
+	padding := []byte{0x80}
+	for (messageLength+len(padding)+8)%64 != 0 {
+		padding = append(padding, 0x00)
+	}
+
+	return binary.LittleEndian.AppendUint64(padding, uint64(messageLength)*8)
+}
+
+func MD4Sum(message []byte) [16]byte {
+	padded := append(append([]byte{}, message...), md4Padding(len(message))...)
+	state := MD4_INITIAL_STATE
+
+	for start := 0; start < len(padded); start += 64 {
+		state = md4Compress(state, md4Words(padded[start:start+64]))
+	}
+
+	var digest [16]byte
+	for i, word := range state {
+		binary.LittleEndian.PutUint32(digest[4*i:], word)
+	}
+
+	return digest
+}
+
+/*
+	Wang's sufficient conditions for the MD4 collision differential (Wang et al. 2005, table 6).
+	Bits are 1-indexed like the paper. kind is '0', '1', '=' (same bit as ref) or '!' (opposite of ref).
+*/
+type md4Condition struct {
+	state string
+	bit   int
+	kind  byte
+	ref   string
+}
+
+var wangRound1Conditions = []md4Condition{
+	{"a1", 7, '=', "b0"},
+	{"d1", 7, '0', ""}, {"d1", 8, '=', "a1"}, {"d1", 11, '=', "a1"},
+	{"c1", 7, '1', ""}, {"c1", 8, '1', ""}, {"c1", 11, '0', ""}, {"c1", 26, '=', "d1"},
+	{"b1", 7, '1', ""}, {"b1", 8, '0', ""}, {"b1", 11, '0', ""}, {"b1", 26, '0', ""},
+	{"a2", 8, '1', ""}, {"a2", 11, '1', ""}, {"a2", 26, '0', ""}, {"a2", 14, '=', "b1"},
+	{"d2", 14, '0', ""}, {"d2", 19, '=', "a2"}, {"d2", 20, '=', "a2"}, {"d2", 21, '=', "a2"}, {"d2", 22, '=', "a2"}, {"d2", 26, '1', ""},
+	{"c2", 13, '=', "d2"}, {"c2", 14, '0', ""}, {"c2", 15, '=', "d2"}, {"c2", 19, '0', ""}, {"c2", 20, '0', ""}, {"c2", 21, '1', ""}, {"c2", 22, '0', ""},
+	{"b2", 13, '1', ""}, {"b2", 14, '1', ""}, {"b2", 15, '0', ""}, {"b2", 17, '=', "c2"}, {"b2", 19, '0', ""}, {"b2", 20, '0', ""}, {"b2", 21, '0', ""}, {"b2", 22, '0', ""},
+	{"a3", 13, '1', ""}, {"a3", 14, '1', ""}, {"a3", 15, '1', ""}, {"a3", 17, '0', ""}, {"a3", 19, '0', ""}, {"a3", 20, '0', ""}, {"a3", 21, '0', ""}, {"a3", 23, '=', "b2"}, {"a3", 22, '1', ""}, {"a3", 26, '=', "b2"},
+	{"d3", 13, '1', ""}, {"d3", 14, '1', ""}, {"d3", 15, '1', ""}, {"d3", 17, '0', ""}, {"d3", 20, '0', ""}, {"d3", 21, '1', ""}, {"d3", 22, '1', ""}, {"d3", 23, '0', ""}, {"d3", 26, '1', ""}, {"d3", 30, '=', "a3"},
+	{"c3", 17, '1', ""}, {"c3", 20, '0', ""}, {"c3", 21, '0', ""}, {"c3", 22, '0', ""}, {"c3", 23, '0', ""}, {"c3", 26, '0', ""}, {"c3", 30, '1', ""}, {"c3", 32, '=', "d3"},
+	{"b3", 20, '0', ""}, {"b3", 21, '1', ""}, {"b3", 22, '1', ""}, {"b3", 23, '=', "c3"}, {"b3", 26, '1', ""}, {"b3", 30, '0', ""}, {"b3", 32, '0', ""},
+	{"a4", 23, '0', ""}, {"a4", 26, '0', ""}, {"a4", 27, '=', "b3"}, {"a4", 29, '=', "b3"}, {"a4", 30, '1', ""}, {"a4", 32, '0', ""},
+	{"d4", 23, '0', ""}, {"d4", 26, '0', ""}, {"d4", 27, '1', ""}, {"d4", 29, '1', ""}, {"d4", 30, '0', ""}, {"d4", 32, '1', ""},
+	{"c4", 19, '=', "d4"}, {"c4", 23, '1', ""}, {"c4", 26, '1', ""}, {"c4", 27, '0', ""}, {"c4", 29, '0', ""}, {"c4", 30, '0', ""},
+	{"b4", 19, '0', ""}, {"b4", 26, '1', ""}, {"b4", 27, '1', ""}, {"b4", 29, '1', ""}, {"b4", 30, '0', ""},
+}
+
+var wangA5Conditions = []md4Condition{
+	{"a5", 19, '=', "c4"}, {"a5", 26, '1', ""}, {"a5", 27, '0', ""}, {"a5", 29, '1', ""}, {"a5", 32, '1', ""},
+}
+
+var wangD5Conditions = []md4Condition{
+	{"d5", 19, '=', "a5"}, {"d5", 26, '=', "b4"}, {"d5", 27, '=', "b4"}, {"d5", 29, '=', "b4"}, {"d5", 32, '=', "b4"},
+}
+
+// Only c5,30 out of c5,26 c5,27 c5,29 c5,30 c5,32, see wangRound2Modification
+var wangC5Conditions = []md4Condition{
+	{"c5", 30, '=', "d5"},
+}
+
+// "a3" -> index into md4Steps' states
+func md4StateIndex(name string) int {
+	k, err := strconv.Atoi(name[1:])
+	if err != nil {
+		log.Fatalf("bad MD4 state name %q", name)
+	}
+
+	return 4*k + strings.IndexByte("adcb", name[0])
+}
+
+func md4Bit(value uint32, bit int) uint32 {
+	return (value >> (bit - 1)) & 1
+}
+
+func (condition md4Condition) wanted(states *[52]uint32) uint32 {
+	switch condition.kind {
+	case '0':
+		return 0
+	case '1':
+		return 1
+	case '=':
+		return md4Bit(states[md4StateIndex(condition.ref)], condition.bit)
+	default:
+		return md4Bit(states[md4StateIndex(condition.ref)], condition.bit) ^ 1
+	}
+}
+
+func (condition md4Condition) holds(states *[52]uint32) bool {
+	return md4Bit(states[md4StateIndex(condition.state)], condition.bit) == condition.wanted(states)
+}
+
+/*
+	Single-step modification for round 1:
+		- Compute each step, force its bits to satisfy the conditions on it
+		- Then solve for the message word that produces the modified value:
+		  m = (value >>> s) - a - F(b, c, d)
+*/
+func wangRound1Modification(words *[16]uint32) {
+	var states [52]uint32
+	states[0], states[1], states[2], states[3] = MD4_INITIAL_STATE[0], MD4_INITIAL_STATE[3], MD4_INITIAL_STATE[2], MD4_INITIAL_STATE[1]
+
+	for t := 0; t < 16; t++ {
+		a, b, c, d := states[t], states[t+3], states[t+2], states[t+1]
+		shift := md4Round1Shifts[t%4]
+
+		states[t+4] = bits.RotateLeft32(a+md4F(b, c, d)+words[t], shift)
+
+		for _, condition := range wangRound1Conditions {
+			if md4StateIndex(condition.state) != t+4 || condition.holds(&states) {
+				continue
+			}
+
+			states[t+4] ^= 1 << (condition.bit - 1)
+		}
+
+		words[t] = bits.RotateLeft32(states[t+4], -shift) - a - md4F(b, c, d)
+	}
+}
+
+// m for round 1 step t, given the states around it
+func md4Round1Word(states *[52]uint32, t int) uint32 {
+	a, b, c, d := states[t], states[t+3], states[t+2], states[t+1]
+
+	return bits.RotateLeft32(states[t+4], -md4Round1Shifts[t%4]) - a - md4F(b, c, d)
+}
+
+/*
+	Multi-step modification for a5, d5 and c5:
+		- a5 uses m0 just like a1 does. Flipping bit i of a1 moves m0 by 2^(i - 4), which flips bit i of a5
+		  (carries permitting). m1..m4 get recomputed so d1, c1, b1 and a2 don't change
+		- d5 uses m4 just like a2 does, with a shift of 5 instead of 3. Flipping bit i - 2 of a2 flips bit i of d5,
+		  then m5..m8 get recomputed so d2, c2, b2 and a3 don't change
+		- c5 uses m8 like a3 does, with a shift of 9. Flipping bit i - 6 of a3 flips bit i of c5,
+		  then m9..m12 get recomputed so d3, c3, b3 and a4 don't change
+	None of the bits flipped in a1, a2 and a3 have round 1 conditions on them. That rules out
+	c5,26 c5,27 c5,29 and c5,32, they would need a3,20 a3,21 a3,23 and a3,26, which round 1 already pins.
+*/
+func wangRound2Modification(words *[16]uint32) {
+	fix := func(conditions []md4Condition, round1Index int, offset int, firstWord int) {
+		states := md4Steps(MD4_INITIAL_STATE, *words)
+
+		for _, condition := range conditions {
+			if condition.holds(&states) {
+				continue
+			}
+
+			states[round1Index] ^= 1 << (condition.bit - 1 - offset)
+
+			for t := firstWord; t < firstWord+5; t++ {
+				words[t] = md4Round1Word(&states, t)
+			}
+
+			states = md4Steps(MD4_INITIAL_STATE, *words)
+		}
+	}
+
+	fix(wangA5Conditions, md4StateIndex("a1"), 0, 0)
+	fix(wangD5Conditions, md4StateIndex("a2"), 2, 4)
+	fix(wangC5Conditions, md4StateIndex("a3"), 6, 8)
+}
+
+// The differential: M'1 = M1 + 2^31, M'2 = M2 + 2^31 - 2^28, M'12 = M12 - 2^16
+func wangPartner(words [16]uint32) [16]uint32 {
+	partner := words
+	partner[1] += 1 << 31
+	partner[2] += (1 << 31) - (1 << 28)
+	partner[12] -= 1 << 16
+
+	return partner
+}
+
+func md4Block(words [16]uint32) []byte {
+	block := make([]byte, 0, 64)
+
+	for _, word := range words {
+		block = binary.LittleEndian.AppendUint32(block, word)
+	}
+
+	return block
+}
+
+type MD4Collision struct {
+	A        []byte
+	B        []byte
+	Attempts int
+}
+
+/*
+	Wang's MD4 collision attack (challenge 55):
+		- Random block, force every round 1 condition with single-step modification
+		- Force the a5, d5 and c5,30 conditions with multi-step modification
+		- Apply the differential and see if the compression function collides,
+		  the rest of c5, b5 onwards and all of round 3 are left to chance
+	Gives up after maxAttempts blocks.
+*/
+func FindMD4Collision(maxAttempts int) (MD4Collision, error) {
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		words := md4Words(GenerateRandomBytes(64))
+
+		wangRound1Modification(&words)
+		wangRound2Modification(&words)
+
+		partner := wangPartner(words)
+
+		if md4Compress(MD4_INITIAL_STATE, words) == md4Compress(MD4_INITIAL_STATE, partner) {
+			return MD4Collision{A: md4Block(words), B: md4Block(partner), Attempts: attempt}, nil
+		}
+	}
+
+	return MD4Collision{}, fmt.Errorf("no collision in %d attempts", maxAttempts)
+}
+
+/*
+	RC4:
+		- Key scheduling shuffles S = 0..255 using the key
+		- Each keystream byte swaps two entries of S and outputs S[S[i] + S[j]]
+*/
+type RC4 struct {
+	s    [256]byte
+	i, j byte
+}
+
+func NewRC4(key []byte) *RC4 {
+	if len(key) == 0 {
+		panic("RC4 key can't be empty")
+	}
+
+	cipher := &RC4{}
+
+	for i := range cipher.s {
+		cipher.s[i] = byte(i)
+	}
+
+	var j byte
+	for i := range cipher.s {
+		j += cipher.s[i] + key[i%len(key)]
+		cipher.s[i], cipher.s[j] = cipher.s[j], cipher.s[i]
+	}
+
+	return cipher
+}
+
+func (cipher *RC4) XORKeyStream(dst []byte, src []byte) {
+	for k, b := range src {
+		cipher.i++
+		cipher.j += cipher.s[cipher.i]
+		cipher.s[cipher.i], cipher.s[cipher.j] = cipher.s[cipher.j], cipher.s[cipher.i]
+
+		dst[k] = b ^ cipher.s[cipher.s[cipher.i]+cipher.s[cipher.j]]
+	}
+}
+
+func RC4Encrypt(data []byte, key []byte) []byte {
+	cipherText := make([]byte, len(data))
+	NewRC4(key).XORKeyStream(cipherText, data)
+
+	return cipherText
+}
+
+const RC4_COOKIE_BASE64 = "QkUgU1VSRSBUTyBEUklOSyBZT1VSIE9WQUxUSU5F"
+
+func RC4Cookie() []byte {
+	cookie, err := base64.StdEncoding.DecodeString(RC4_COOKIE_BASE64)
+	if err != nil {
+		log.Fatalf("error decoding cookie: %v", err)
+	}
+
+	return cookie
+}
+
+/*
+	Broadcast attack setup (challenge 56):
+		- The attacker controls a request path that gets sent ahead of the cookie
+		- Every encryption uses a fresh 128 bit key
+*/
+type RC4Oracle struct {
+	cookie []byte
+}
+
+func NewRC4Oracle(cookie []byte) RC4Oracle {
+	return RC4Oracle{cookie: cookie}
+}
+
+func (oracle RC4Oracle) Encrypt(request []byte) []byte {
+	plainText := append(append([]byte{}, request...), oracle.cookie...)
+
+	return RC4Encrypt(plainText, GenerateRandomBytes(16))
+}
+
+/*
+	Single-byte biases of the RC4 keystream (AlFardan et al.), 0-indexed:
+		- Z16 (index 15) is 240 more often than it should be
+		- Z32 (index 31) is 224 more often than it should be
+*/
+var RC4_BIASES = [2]struct {
+	Index int
+	Value byte
+}{{15, 240}, {31, 224}}
+
+/*
+	Encrypts 'A' * prefixLength || cookie samples times, split across goroutines,
+	and counts every cipherText byte seen at each biased index.
+*/
+func rc4BiasCounts(oracle RC4Oracle, prefixLength int, samples int, workers int) [2][256]int {
+	if workers < 1 {
+		workers = 1
+	}
+
+	request := bytes.Repeat([]byte{'A'}, prefixLength)
+
+	var counts [2][256]int
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		share := samples / workers
+		if w < samples%workers {
+			share++
+		}
+
+		wg.Add(1)
+
+		go func(share int) {
+			defer wg.Done()
+
+			var local [2][256]int
+
+			for n := 0; n < share; n++ {
+				cipherText := oracle.Encrypt(request)
+
+				for b, bias := range RC4_BIASES {
+					if bias.Index < len(cipherText) {
+						local[b][cipherText[bias.Index]]++
+					}
+				}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			for b := range counts {
+				for value, count := range local[b] {
+					counts[b][value] += count
+				}
+			}
+		}(share)
+	}
+
+	wg.Wait()
+
+	return counts
+}
+
+// The most common cipherText byte at a biased index is most likely the plainText byte XOR the bias
+func rc4MostLikelyByte(counts [256]int, bias byte) byte {
+	best := 0
+
+	for value, count := range counts {
+		if count > counts[best] {
+			best = value
+		}
+	}
+
+	return byte(best) ^ bias
+}
+
+/*
+	One batch of the attack: encrypts with prefixLength bytes of prefix and fills in
+	whichever cookie bytes that lines up with the biases.
+*/
+func rc4RecoverBytes(oracle RC4Oracle, cookie []byte, prefixLength int, samples int, workers int) {
+	counts := rc4BiasCounts(oracle, prefixLength, samples, workers)
+	firstIndex := RC4_BIASES[0].Index
+
+	for b, bias := range RC4_BIASES {
+		index := bias.Index - prefixLength
+
+		// Z32 only covers bytes Z16 can't
+		if index < 0 || index >= len(cookie) || (b > 0 && index <= firstIndex) {
+			continue
+		}
+
+		cookie[index] = rc4MostLikelyByte(counts[b], bias.Value)
+	}
+}
+
+/*
+	Single-byte bias attack:
+		- A prefix of p bytes puts cookie byte 15 - p on Z16 and cookie byte 31 - p on Z32
+		- So 16 prefix lengths cover a cookie of up to 32 bytes, two bytes per batch of encryptions
+		- Each byte needs on the order of 2^24 samples to come out right reliably
+*/
+func RC4BiasAttack(oracle RC4Oracle, samples int, workers int) ([]byte, error) {
+	cookieLength := len(oracle.Encrypt(nil))
+
+	lastIndex := RC4_BIASES[len(RC4_BIASES)-1].Index
+	if cookieLength > lastIndex+1 {
+		return nil, fmt.Errorf("cookie is %d bytes, the biases only reach the first %d", cookieLength, lastIndex+1)
+	}
+
+	cookie := make([]byte, cookieLength)
+	firstIndex := RC4_BIASES[0].Index
+
+	for prefixLength := firstIndex; prefixLength >= 0 && firstIndex-prefixLength < cookieLength; prefixLength-- {
+		rc4RecoverBytes(oracle, cookie, prefixLength, samples, workers)
+	}
+
+	return cookie, nil
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCBCMACTransfer(t *testing.T) {
+	key := GenerateRandomBytes(16)
+	server := NewTransactionServer(key)
+	client := NewTransactionClient(key, "17")
+
+	transfer, err := server.ProcessTransfer(client.Transfer("42", 100))
+
+	assert.Nil(t, err)
+	assert.Equal(t, Transfer{From: "17", To: "42", Amount: 100}, transfer)
+
+	tampered := client.Transfer("42", 100)
+	tampered[len("from=17&to=4")] = '3'
+
+	_, err = server.ProcessTransfer(tampered)
+	assert.NotNil(t, err)
+}
+
+func TestCBCMACIVForgery(t *testing.T) {
+	/*
+		- Attacker (account 66) can only get their own transfers signed
+		- Flip the from ID in the first block and the same bits in the IV
+	*/
+	key := GenerateRandomBytes(16)
+	server := NewTransactionServer(key)
+	attacker := NewTransactionClient(key, "66")
+
+	forged, err := ForgeTransferIV(attacker.Transfer("66", 1000000), "17")
+	assert.Nil(t, err)
+
+	transfer, err := server.ProcessTransfer(forged)
+
+	fmt.Printf("forged transfer: %+v\n", transfer)
+
+	assert.Nil(t, err)
+	assert.Equal(t, Transfer{From: "17", To: "66", Amount: 1000000}, transfer)
+
+	short := append([]byte("from=66&to=1"), make([]byte, 2*BLOCK_SIZE)...)
+	_, err = ForgeTransferIV(short, "17")
+
+	assert.NotNil(t, err)
+}
+
+func TestCBCMACLengthExtension(t *testing.T) {
+	/*
+		- Capture the victim's (account 17) signed transaction list
+		- Glue the attacker's own signed list onto it, XORing its first block with the captured MAC
+	*/
+	key := GenerateRandomBytes(16)
+	server := NewTransactionServer(key)
+	victim := NewTransactionClient(key, "17")
+	attacker := NewTransactionClient(key, "66")
+
+	// first block is "from=66&tx_list=", everything after it ends up in the victim's request
+	attackerRequest := attacker.TransactionList([]Transaction{{To: "66", Amount: 1}, {To: "66", Amount: 1000000}})
+
+	// keep capturing until the glue block is usable
+	var forged []byte
+	var err error
+
+	for amount := 300; forged == nil && amount < 400; amount++ {
+		captured := victim.TransactionList([]Transaction{{To: "5", Amount: 20}, {To: "8", Amount: amount}})
+
+		forged, err = ForgeTransactionListExtension(captured, attackerRequest)
+	}
+	assert.Nil(t, err)
+
+	list, err := server.ProcessTransactionList(forged)
+
+	fmt.Printf("forged transaction list: %+v\n", list)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "17", list.From)
+	assert.Contains(t, list.Transactions, Transaction{To: "5", Amount: 20})
+	assert.Contains(t, list.Transactions, Transaction{To: "66", Amount: 1000000})
+}
+
+func TestCBCMACHashCollision(t *testing.T) {
+	/*
+		- CBC-MAC with a known key is not a hash
+		- New snippet, comment it out, then a glue block that lands the CBC state on the original's
+	*/
+	original := []byte("alert('MZA who was that?');\n")
+	target := []byte("alert('Ayo, the Wu is back!');")
+
+	assert.Equal(t, "296b8d7cb78a243dda4d0a61d33bbdd1", fmt.Sprintf("%x", CBCMACHash(original)))
+
+	forged, printable, err := ForgeCBCMACHashCollision(target, original, 1<<28)
+
+	fmt.Printf("forged snippet: %q printable: %v\n", forged, printable)
+
+	assert.Nil(t, err)
+	assert.True(t, printable)
+	assert.Equal(t, CBCMACHash(original), CBCMACHash(forged))
+	assert.True(t, strings.HasPrefix(string(forged), string(target)+"//"))
+	assert.Equal(t, 1, strings.Count(string(forged), "\n"))
+}
+
+func TestCompressionAttack(t *testing.T) {
+	/*
+		- Request gets compressed before it's encrypted, only the length leaks
+		- Guesses that match the cookie compress better
+		- CBC hides small differences in whole blocks, padding moves the length to a block edge
+	*/
+	sessionID := "TmV2ZXIgcmV2ZWFsIHRoZSBXdS1UYW5nIFNlY3JldCE="
+
+	for _, mode := range []string{"CTR", "CBC"} {
+		oracle := NewCompressionOracle(sessionID, mode)
+
+		recovered, err := CompressionAttack(oracle, "sessionid=", 64)
+
+		fmt.Printf("%v: %v\n", mode, recovered)
+
+		assert.Nil(t, err)
+		assert.Equal(t, sessionID, recovered)
+	}
+
+	assert.Panics(t, func() { NewCompressionOracle(sessionID, "ctr").Length(nil) })
+}
+
+func TestMDHash(t *testing.T) {
+	hash := NewMDHash(16, 0xbeef)
+
+	assert.Equal(t, hash.Sum([]byte("hello")), hash.Sum([]byte("hello")))
+	assert.NotEqual(t, hash.Sum([]byte("hello")), hash.Sum([]byte("hello!")))
+	assert.Less(t, hash.Sum([]byte("hello")), uint32(1<<16))
+	assert.Len(t, append([]byte("hello"), MDPadding(5)...), BLOCK_SIZE)
+	assert.Len(t, append(make([]byte, 9), MDPadding(9)...), 2*BLOCK_SIZE)
+}
+
+func TestJouxMulticollision(t *testing.T) {
+	/*
+		- n birthday searches give 2^n colliding messages
+	*/
+	hash := NewMDHash(16, 0xbeef)
+	multicollision := hash.FindMulticollision(hash.Initial, 4)
+
+	assert.Equal(t, uint64(16), multicollision.Count())
+
+	expected := hash.Sum(multicollision.Message(0))
+	messages := make(map[string]bool)
+
+	for i := uint64(0); i < multicollision.Count(); i++ {
+		message := multicollision.Message(i)
+		messages[string(message)] = true
+
+		assert.Equal(t, expected, hash.Sum(message))
+	}
+
+	assert.Len(t, messages, 16)
+}
+
+func TestBreakCascade(t *testing.T) {
+	/*
+		- f is 16 bits, g is 32 bits, h = f || g is 48 bits
+		- Multicollision in f big enough to expect a collision in g
+	*/
+	cheap := NewMDHash(16, 0xbeef)
+	costly := NewMDHash(32, 0xdeadbeef)
+
+	collision := BreakCascade(cheap, costly)
+
+	fmt.Printf("cascade collision after %d calls to f and %d calls to g\n", collision.CheapCalls, collision.CostlyCalls)
+
+	assert.NotEqual(t, collision.A, collision.B)
+	assert.Equal(t, cheap.Sum(collision.A), cheap.Sum(collision.B))
+	assert.Equal(t, costly.Sum(collision.A), costly.Sum(collision.B))
+}
+
+func TestExpandableMessage(t *testing.T) {
+	hash := NewMDHash(16, 0xbeef)
+	expandable := hash.BuildExpandableMessage(hash.Initial, 4)
+
+	assert.Equal(t, 4, expandable.MinBlocks())
+	assert.Equal(t, 19, expandable.MaxBlocks())
+
+	for blocks := expandable.MinBlocks(); blocks <= expandable.MaxBlocks(); blocks++ {
+		message, err := expandable.Produce(blocks)
+
+		assert.Nil(t, err)
+		assert.Len(t, message, blocks*BLOCK_SIZE)
+		assert.Equal(t, expandable.State, hash.Iterate(hash.Initial, message))
+	}
+
+	_, err := expandable.Produce(20)
+	assert.NotNil(t, err)
+}
+
+func TestKelseySchneierSecondPreimage(t *testing.T) {
+	/*
+		- 2^10 block message, 24 bit hash
+		- Expandable message + one bridge block into the middle of the original
+	*/
+	hash := NewMDHash(24, 0xbeef)
+	message := GenerateRandomBytes((1 << 10) * BLOCK_SIZE)
+
+	preimage, err := hash.FindSecondPreimage(message)
+
+	fmt.Printf("second preimage: %d blocks, %d hash calls\n", preimage.Blocks, preimage.Calls)
+
+	assert.Nil(t, err)
+	assert.NotEqual(t, message, preimage.Message)
+	assert.Equal(t, len(message), len(preimage.Message))
+	assert.Equal(t, hash.Sum(message), hash.Sum(preimage.Message))
+}
+
+func TestNostradamusHerding(t *testing.T) {
+	/*
+		- Commit to a hash before knowing the prefix
+		- 2^8 leaf diamond on a 24 bit hash
+		- Any prefix links into the diamond with one block, then follows it to the committed root
+	*/
+	hash := NewMDHash(24, 0xbeef)
+	diamond := BuildDiamond(hash, 8, 8)
+	diamondCalls := hash.Calls()
+
+	committed := diamond.Commit(4)
+
+	prefix := []byte("Final score: Patriots 31, Giants 17. Hall of fame career")
+	message, err := diamond.Herd(prefix, 4, 8)
+
+	fmt.Printf("diamond: %d calls, herding: %d calls\n", diamondCalls, hash.Calls()-diamondCalls)
+
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(string(message), string(prefix)))
+	assert.Len(t, message, (4+1+8)*BLOCK_SIZE)
+	assert.Equal(t, committed, hash.Sum(message))
+
+	_, err = diamond.Herd(make([]byte, 5*BLOCK_SIZE), 4, 8)
+	assert.NotNil(t, err)
+}
+
+func TestMD4(t *testing.T) {
+	// RFC 1320 test suite
+	vectors := map[string]string{
+		"":               "31d6cfe0d16ae931b73c59d7e0c089c0",
+		"a":              "bde52cb31de33e46245e05fbdbd6fb24",
+		"abc":            "a448017aaf21d8525fc10ae87aa6729d",
+		"message digest": "d9130a8164549fe818874806e1c7014b",
+		"12345678901234567890123456789012345678901234567890123456789012345678901234567890": "e33b4ddc9c38f2199c3e7b164fcc0536",
+	}
+
+	for message, digest := range vectors {
+		sum := MD4Sum([]byte(message))
+
+		assert.Equal(t, digest, fmt.Sprintf("%x", sum[:]))
+	}
+}
+
+func TestWangRound1Modification(t *testing.T) {
+	words := md4Words(GenerateRandomBytes(64))
+
+	wangRound1Modification(&words)
+	wangRound2Modification(&words)
+
+	states := md4Steps(MD4_INITIAL_STATE, words)
+
+	for _, condition := range wangRound1Conditions {
+		assert.True(t, condition.holds(&states), "%v,%d", condition.state, condition.bit)
+	}
+
+	// the round 2 fixes only miss when a carry runs into the next bit, so a handful of blocks is plenty
+	holds := 0
+	for i := 0; i < 16; i++ {
+		words := md4Words(GenerateRandomBytes(64))
+
+		wangRound1Modification(&words)
+		wangRound2Modification(&words)
+
+		states := md4Steps(MD4_INITIAL_STATE, words)
+		if wangC5Conditions[0].holds(&states) {
+			holds++
+		}
+	}
+
+	assert.Greater(t, holds, 8)
+}
+
+func TestMD4Collision(t *testing.T) {
+	/*
+		- Message modification makes most of the differential's conditions hold for free
+		- The rest are left to chance, so it still takes tens of thousands of tries
+	*/
+	if testing.Short() {
+		t.Skip("collision search takes a while")
+	}
+
+	collision, err := FindMD4Collision(1 << 30)
+
+	fmt.Printf("MD4 collision after %d attempts:\n%x\n%x\n", collision.Attempts, collision.A, collision.B)
+
+	assert.Nil(t, err)
+	assert.NotEqual(t, collision.A, collision.B)
+	assert.Equal(t, MD4Sum(collision.A), MD4Sum(collision.B))
+}
+
+func TestRC4(t *testing.T) {
+	// Wikipedia test vectors
+	assert.Equal(t, "bbf316e8d940af0ad3", fmt.Sprintf("%x", RC4Encrypt([]byte("Plaintext"), []byte("Key"))))
+	assert.Equal(t, "1021bf0420", fmt.Sprintf("%x", RC4Encrypt([]byte("pedia"), []byte("Wiki"))))
+
+	message := []byte("Attack at dawn")
+	key := GenerateRandomBytes(16)
+
+	assert.Equal(t, message, RC4Encrypt(RC4Encrypt(message, key), key))
+	assert.Equal(t, "BE SURE TO DRINK YOUR OVALTINE", string(RC4Cookie()))
+	assert.PanicsWithValue(t, "RC4 key can't be empty", func() { NewRC4(nil) })
+}
+
+func TestRC4BiasAttack(t *testing.T) {
+	/*
+		- Recovering all 30 bytes of the real cookie takes 2^24 encryptions per prefix length
+		- A 2 byte cookie only needs two prefix lengths, 2^22 samples per length isn't quite enough
+		  for Z16 to win every time
+	*/
+	if testing.Short() {
+		t.Skip("millions of RC4 encryptions")
+	}
+
+	oracle := NewRC4Oracle([]byte("BE"))
+
+	cookie, err := RC4BiasAttack(oracle, 1<<24, 4)
+
+	fmt.Printf("recovered cookie: %q\n", cookie)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("BE"), cookie)
+
+	_, err = RC4BiasAttack(NewRC4Oracle(make([]byte, 33)), 1, 1)
+
+	assert.NotNil(t, err)
+}
+
+func TestRC4Z32Bias(t *testing.T) {
+	/*
+		- With 15 bytes of prefix, cookie byte 0 lands on Z16 and cookie byte 16 on Z32
+		- That's the first batch RC4BiasAttack runs on a 17 byte cookie
+		- Z32 is weaker than Z16 but 2^24 samples still make it stand out
+	*/
+	if testing.Short() {
+		t.Skip("millions of RC4 encryptions")
+	}
+
+	secret := []byte("O...............K")
+	cookie := make([]byte, len(secret))
+
+	rc4RecoverBytes(NewRC4Oracle(secret), cookie, 15, 1<<24, 4)
+
+	fmt.Printf("Z16 recovered %q, Z32 recovered %q\n", cookie[0], cookie[16])
+
+	assert.Equal(t, secret[0], cookie[0])
+	assert.Equal(t, secret[16], cookie[16])
+	assert.Equal(t, make([]byte, 15), cookie[1:16])
+}
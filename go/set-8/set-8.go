@@ -0,0 +1,1595 @@
+package main
+
+import (
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+)
+
+func GenerateRandomBytes(byteLength int) []byte {
+	token := make([]byte, byteLength)
+
+	_, err := rand.Read(token)
+	if err != nil {
+		log.Fatalf("error generating random key: %v", err)
+	}
+
+	return token
+}
+
+// Random big.Int in [0, max)
+func GenerateRandomBigInt(max *big.Int) *big.Int {
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		log.Fatalf("error generating random number: %v", err)
+	}
+
+	return n
+}
+
+func hmacSHA256(key []byte, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+
+	return mac.Sum(nil)
+}
+
+/*
+	Modular inverse with the extended Euclidean algorithm:
+		- Keeps a = x * a0 (mod m) while running Euclid on (a, m)
+		- When the remainder hits 1, x is the inverse
+		- If the gcd isn't 1 there is no inverse
+*/
+func InvMod(a *big.Int, m *big.Int) (*big.Int, error) {
+	if m.Sign() <= 0 {
+		return nil, errors.New("modulus must be positive")
+	}
+
+	oldR, r := new(big.Int).Mod(a, m), new(big.Int).Set(m)
+	oldX, x := big.NewInt(1), big.NewInt(0)
+
+	for r.Sign() != 0 {
+		quotient := new(big.Int).Div(oldR, r)
+
+		oldR, r = r, new(big.Int).Sub(oldR, new(big.Int).Mul(quotient, r))
+		oldX, x = x, new(big.Int).Sub(oldX, new(big.Int).Mul(quotient, x))
+	}
+
+	if oldR.Cmp(big.NewInt(1)) != 0 {
+		return nil, fmt.Errorf("%v has no inverse mod %v", a, m)
+	}
+
+	return oldX.Mod(oldX, m), nil
+}
+
+/*
+	Chinese Remainder Theorem:
+		- Finds x with x = residues[i] mod moduli[i] for every i (moduli pairwise coprime)
+		- x = sum(r_i * m_s_i * invmod(m_s_i, n_i)) mod N, where m_s_i is the product of every modulus except n_i
+	Returns x and N, the product of all the moduli.
+*/
+func CRT(residues []*big.Int, moduli []*big.Int) (*big.Int, *big.Int, error) {
+	if len(residues) != len(moduli) || len(moduli) == 0 {
+		return nil, nil, errors.New("need one residue per modulus")
+	}
+
+	product := big.NewInt(1)
+	for _, modulus := range moduli {
+		product.Mul(product, modulus)
+	}
+
+	result := big.NewInt(0)
+
+	for i, residue := range residues {
+		ms := new(big.Int).Div(product, moduli[i])
+
+		inverse, err := InvMod(ms, moduli[i])
+		if err != nil {
+			return nil, nil, fmt.Errorf("moduli aren't coprime: %v", err)
+		}
+
+		term := new(big.Int).Mul(residue, ms)
+		term.Mul(term, inverse)
+		result.Add(result, term)
+	}
+
+	return result.Mod(result, product), product, nil
+}
+
+func parseDecimalInt(value string) *big.Int {
+	n, ok := new(big.Int).SetString(value, 10)
+	if !ok {
+		log.Fatalf("unable to parse %q", value)
+	}
+
+	return n
+}
+
+/*
+	DH group where g generates a subgroup of prime order q,
+	q divides p - 1 and j = (p - 1) / q is left with lots of small factors.
+*/
+type DHGroup struct {
+	P *big.Int
+	G *big.Int
+	Q *big.Int
+}
+
+// Group from challenge 57
+func SubgroupConfinementGroup() DHGroup {
+	return DHGroup{
+		P: parseDecimalInt("7199773997391911030609999317773941274322764333428698921736339643928346453700085358802973900485592910475480089726140708102474957429903531369589969318716771"),
+		G: parseDecimalInt("4565356397095740655436854503483826832136106141639563487732438195343690437606117828318042418238184896212352329118608100083187535033402010599512641674644143"),
+		Q: parseDecimalInt("236234353446506858198510045061214171961"),
+	}
+}
+
+// j = (p - 1) / q
+func (group DHGroup) Cofactor() *big.Int {
+	j := new(big.Int).Sub(group.P, big.NewInt(1))
+
+	return j.Div(j, group.Q)
+}
+
+func (group DHGroup) GenerateKeyPair() (*big.Int, *big.Int) {
+	// private key in [1, q)
+	private := GenerateRandomBigInt(new(big.Int).Sub(group.Q, big.NewInt(1)))
+	private.Add(private, big.NewInt(1))
+
+	return private, new(big.Int).Exp(group.G, private, group.P)
+}
+
+/*
+	A public key is only safe to use if it lives in the order q subgroup:
+		- 1 < h < p - 1
+		- h^q = 1 mod p
+*/
+func (group DHGroup) ValidatePublicKey(h *big.Int) error {
+	if h.Cmp(big.NewInt(1)) <= 0 || h.Cmp(new(big.Int).Sub(group.P, big.NewInt(1))) >= 0 {
+		return errors.New("public key out of range")
+	}
+
+	if new(big.Int).Exp(h, group.Q, group.P).Cmp(big.NewInt(1)) != 0 {
+		return errors.New("public key isn't in the order q subgroup")
+	}
+
+	return nil
+}
+
+type DHResponse struct {
+	Message []byte
+	MAC     []byte
+}
+
+/*
+	Bob from challenge 57:
+		- Takes anyone's public key h and computes K = h^x mod p with his long term private key x
+		- Answers with a message and its HMAC-SHA256 under K
+	SkipPublicKeyValidation turns off the subgroup check, which is what lets the attack through.
+*/
+type DHServer struct {
+	group                   DHGroup
+	private                 *big.Int
+	public                  *big.Int
+	Message                 []byte
+	SkipPublicKeyValidation bool
+}
+
+func NewDHServer(group DHGroup) *DHServer {
+	private, public := group.GenerateKeyPair()
+
+	return &DHServer{
+		group:   group,
+		private: private,
+		public:  public,
+		Message: []byte("crazy flamboyant for the rap enjoyment"),
+	}
+}
+
+func (server *DHServer) PublicKey() *big.Int {
+	return server.public
+}
+
+func (server *DHServer) Respond(h *big.Int) (DHResponse, error) {
+	if !server.SkipPublicKeyValidation {
+		if err := server.group.ValidatePublicKey(h); err != nil {
+			return DHResponse{}, err
+		}
+	}
+
+	K := new(big.Int).Exp(h, server.private, server.group.P)
+
+	return DHResponse{Message: server.Message, MAC: hmacSHA256(K.Bytes(), server.Message)}, nil
+}
+
+// Distinct prime factors of n below bound, found with trial division
+func SmallFactors(n *big.Int, bound int64) []*big.Int {
+	factors := make([]*big.Int, 0)
+	remaining := new(big.Int).Set(n)
+	rem := new(big.Int)
+
+	for d := int64(2); d < bound; d++ {
+		divisor := big.NewInt(d)
+
+		if rem.Mod(remaining, divisor).Sign() != 0 {
+			continue
+		}
+
+		factors = append(factors, divisor)
+
+		for rem.Mod(remaining, divisor).Sign() == 0 {
+			remaining.Div(remaining, divisor)
+		}
+	}
+
+	return factors
+}
+
+// h = rand^((p - 1) / r) mod p has order r (r prime), unless it comes out as 1
+func SmallOrderElement(p *big.Int, r *big.Int) *big.Int {
+	exponent := new(big.Int).Sub(p, big.NewInt(1))
+	exponent.Div(exponent, r)
+
+	for {
+		h := new(big.Int).Exp(GenerateRandomBigInt(p), exponent, p)
+
+		if h.Cmp(big.NewInt(1)) != 0 {
+			return h
+		}
+	}
+}
+
+// K = h^x can only take r values, so try h^b for every b in [0, r) against the MAC
+func recoverResidue(response DHResponse, h *big.Int, r *big.Int, p *big.Int) (*big.Int, error) {
+	K := big.NewInt(1)
+
+	for b := int64(0); b < r.Int64(); b++ {
+		if hmac.Equal(hmacSHA256(K.Bytes(), response.Message), response.MAC) {
+			return big.NewInt(b), nil
+		}
+
+		K.Mul(K, h)
+		K.Mod(K, p)
+	}
+
+	return nil, fmt.Errorf("no residue mod %v matches the MAC", r)
+}
+
+/*
+	Pohlig-Hellman through subgroup confinement:
+		- For every small factor r of j, send the server an element h of order r
+		- The MAC it sends back is keyed with h^x = h^(x mod r), brute force x mod r
+		- CRT the residues together, stopping once the moduli cover q
+	Returns x mod the product of the moduli used. Factors are limited to below bound.
+*/
+func SubgroupConfinementResidues(server *DHServer, group DHGroup, bound int64) (*big.Int, *big.Int, error) {
+	residues := make([]*big.Int, 0)
+	moduli := make([]*big.Int, 0)
+	product := big.NewInt(1)
+
+	for _, r := range SmallFactors(group.Cofactor(), bound) {
+		// r has to be coprime to q for the CRT (and to be of any use)
+		if new(big.Int).Mod(group.Q, r).Sign() == 0 {
+			continue
+		}
+
+		h := SmallOrderElement(group.P, r)
+
+		response, err := server.Respond(h)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		residue, err := recoverResidue(response, h, r, group.P)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		residues = append(residues, residue)
+		moduli = append(moduli, r)
+		product.Mul(product, r)
+
+		if product.Cmp(group.Q) > 0 {
+			break
+		}
+	}
+
+	if len(moduli) == 0 {
+		return nil, nil, errors.New("no small factors to work with")
+	}
+
+	return CRT(residues, moduli)
+}
+
+// Full private key, as long as the small factors of j multiply past q (they do for challenge 57)
+func SubgroupConfinementAttack(server *DHServer, group DHGroup) (*big.Int, error) {
+	x, modulus, err := SubgroupConfinementResidues(server, group, 1<<16)
+	if err != nil {
+		return nil, err
+	}
+
+	if modulus.Cmp(group.Q) <= 0 {
+		return nil, fmt.Errorf("small factors only cover %d of the %d bits of q", modulus.BitLen()-1, group.Q.BitLen())
+	}
+
+	return x, nil
+}
+
+/*
+	What Pollard's kangaroo needs from a group:
+		- Mul and Exp for the group operation and repeated operation
+		- Label maps an element to an integer for the jump function to read
+	Elements are *big.Int for Z_p^*, but anything with these operations works.
+*/
+type KangarooGroup[E any] interface {
+	Mul(a E, b E) E
+	Exp(base E, exponent *big.Int) E
+	Equal(a E, b E) bool
+	Label(element E) *big.Int
+}
+
+// Multiplicative group mod P
+type ModPGroup struct {
+	P *big.Int
+}
+
+func (group ModPGroup) Mul(a *big.Int, b *big.Int) *big.Int {
+	product := new(big.Int).Mul(a, b)
+
+	return product.Mod(product, group.P)
+}
+
+func (group ModPGroup) Exp(base *big.Int, exponent *big.Int) *big.Int {
+	return new(big.Int).Exp(base, exponent, group.P)
+}
+
+func (group ModPGroup) Equal(a *big.Int, b *big.Int) bool {
+	return a.Cmp(b) == 0
+}
+
+func (group ModPGroup) Label(element *big.Int) *big.Int {
+	return element
+}
+
+/*
+	Pseudorandom jump function: a kangaroo sitting on y jumps Distances[Pick(label of y)].
+	It only has to be deterministic so that two kangaroos landing on the same element follow the same path.
+*/
+type KangarooJumps struct {
+	Distances []*big.Int
+	Pick      func(label *big.Int) int
+}
+
+// f(y) = 2^(y mod k), the jump function from challenge 58
+func NewPowerOfTwoJumps(k int) KangarooJumps {
+	distances := make([]*big.Int, k)
+	for i := range distances {
+		distances[i] = new(big.Int).Lsh(big.NewInt(1), uint(i))
+	}
+
+	return KangarooJumps{
+		Distances: distances,
+		Pick: func(label *big.Int) int {
+			return int(label.Uint64() % uint64(k))
+		},
+	}
+}
+
+// Smallest k with a mean jump of at least sqrt(b - a) / 2
+func PowerOfTwoJumpsForInterval(width *big.Int) KangarooJumps {
+	target := new(big.Int).Sqrt(width)
+	target.Rsh(target, 1)
+
+	k := 1
+	for NewPowerOfTwoJumps(k).Mean().Cmp(target) < 0 {
+		k++
+	}
+
+	return NewPowerOfTwoJumps(k)
+}
+
+// Mean jump, assuming Pick spreads evenly over Distances
+func (jumps KangarooJumps) Mean() *big.Int {
+	sum := big.NewInt(0)
+	for _, distance := range jumps.Distances {
+		sum.Add(sum, distance)
+	}
+
+	return sum.Div(sum, big.NewInt(int64(len(jumps.Distances))))
+}
+
+/*
+	The tame half of Pollard's kangaroo: starts at g^b and makes N = 4 * mean jumps, leaving a trap at g^(b + xT).
+	It doesn't depend on y, so one trap can catch any number of wild kangaroos.
+*/
+type KangarooTrap[E any] struct {
+	group        KangarooGroup[E]
+	jumps        KangarooJumps
+	jumpElements []E
+	b            *big.Int
+	xT           *big.Int
+	yT           E
+}
+
+func NewKangarooTrap[E any](group KangarooGroup[E], g E, b *big.Int, jumps KangarooJumps) *KangarooTrap[E] {
+	jumpElements := make([]E, len(jumps.Distances))
+	for i, distance := range jumps.Distances {
+		jumpElements[i] = group.Exp(g, distance)
+	}
+
+	N := new(big.Int).Mul(jumps.Mean(), big.NewInt(4))
+
+	xT := big.NewInt(0)
+	yT := group.Exp(g, b)
+
+	for i := big.NewInt(0); i.Cmp(N) < 0; i.Add(i, big.NewInt(1)) {
+		jump := jumps.Pick(group.Label(yT))
+
+		xT.Add(xT, jumps.Distances[jump])
+		yT = group.Mul(yT, jumpElements[jump])
+	}
+
+	return &KangarooTrap[E]{group: group, jumps: jumps, jumpElements: jumpElements, b: b, xT: xT, yT: yT}
+}
+
+/*
+	The wild half, finds x in [a, b] with y = g^x:
+		- The wild kangaroo starts at y and jumps until it either lands in the trap
+		  or has travelled past it (xW > b - a + xT)
+		- Once both land on the same element they walk the same path, so x = b + xT - xW
+*/
+func (trap *KangarooTrap[E]) Catch(y E, a *big.Int) (*big.Int, error) {
+	limit := new(big.Int).Sub(trap.b, a)
+	limit.Add(limit, trap.xT)
+
+	xW := big.NewInt(0)
+	yW := y
+
+	for xW.Cmp(limit) <= 0 {
+		if trap.group.Equal(yW, trap.yT) {
+			x := new(big.Int).Add(trap.b, trap.xT)
+			return x.Sub(x, xW), nil
+		}
+
+		jump := trap.jumps.Pick(trap.group.Label(yW))
+
+		xW.Add(xW, trap.jumps.Distances[jump])
+		yW = trap.group.Mul(yW, trap.jumpElements[jump])
+	}
+
+	return nil, errors.New("wild kangaroo never landed in the trap")
+}
+
+/*
+	Pollard's kangaroo, finds x in [a, b] with y = g^x.
+	Takes about sqrt(b - a) group operations. It can miss, in which case try another jump function.
+*/
+func Kangaroo[E any](group KangarooGroup[E], g E, y E, a *big.Int, b *big.Int, jumps KangarooJumps) (*big.Int, error) {
+	return NewKangarooTrap(group, g, b, jumps).Catch(y, a)
+}
+
+// Tries every x in [a, b], the baseline for the kangaroo benchmarks
+func BruteForceDiscreteLog[E any](group KangarooGroup[E], g E, y E, a *big.Int, b *big.Int) (*big.Int, error) {
+	current := group.Exp(g, a)
+
+	for x := new(big.Int).Set(a); x.Cmp(b) <= 0; x.Add(x, big.NewInt(1)) {
+		if group.Equal(current, y) {
+			return x, nil
+		}
+
+		current = group.Mul(current, g)
+	}
+
+	return nil, fmt.Errorf("no discrete log in [%v, %v]", a, b)
+}
+
+// Group from challenge 58, the small factors of j don't multiply past q this time
+func KangarooChallengeGroup() DHGroup {
+	return DHGroup{
+		P: parseDecimalInt("11470374874925275658116663507232161402086650258453896274534991676898999262641581519101074740642369848233294239851519212341844337347119899874391456329785623"),
+		G: parseDecimalInt("622952335333961296978159266084741085889881358738459939978290179936063635566740258555167783009058567397963466103140082647486611657350811560630587013183357"),
+		Q: parseDecimalInt("335062023296420808191071248367701059461"),
+	}
+}
+
+/*
+	Subgroup confinement plus kangaroo (challenge 58):
+		- Subgroup confinement gives n = x mod r, with r the product of the small factors
+		- So x = n + m * r with m in [0, (q - 1) / r]
+		- y' = y * g^-n = (g^r)^m, kangaroo m out of the interval with g' = g^r
+	A miss gets retried with a bigger jump set.
+*/
+func SubgroupConfinementKangarooAttack(server *DHServer, group DHGroup) (*big.Int, error) {
+	n, r, err := SubgroupConfinementResidues(server, group, 1<<16)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.Cmp(group.Q) > 0 {
+		return n, nil
+	}
+
+	modP := ModPGroup{P: group.P}
+
+	gInverse, err := InvMod(group.G, group.P)
+	if err != nil {
+		return nil, err
+	}
+
+	gPrime := modP.Exp(group.G, r)
+	yPrime := modP.Mul(server.PublicKey(), modP.Exp(gInverse, n))
+
+	width := new(big.Int).Sub(group.Q, big.NewInt(1))
+	width.Div(width, r)
+
+	k := len(PowerOfTwoJumpsForInterval(width).Distances)
+
+	for attempt := 0; attempt < 3; attempt++ {
+		m, err := Kangaroo[*big.Int](modP, gPrime, yPrime, big.NewInt(0), width, NewPowerOfTwoJumps(k+attempt))
+		if err != nil {
+			continue
+		}
+
+		x := new(big.Int).Mul(m, r)
+		return x.Add(x, n), nil
+	}
+
+	return nil, fmt.Errorf("kangaroo didn't find x mod q in [0, %v]", width)
+}
+
+/*
+	Affine point on a short Weierstrass curve. The identity (point at infinity) has no coordinates,
+	Infinity is set instead.
+*/
+type ECPoint struct {
+	X        *big.Int
+	Y        *big.Int
+	Infinity bool
+}
+
+func ECIdentity() ECPoint {
+	return ECPoint{Infinity: true}
+}
+
+func NewECPoint(x *big.Int, y *big.Int) ECPoint {
+	return ECPoint{X: x, Y: y}
+}
+
+func (point ECPoint) Equal(other ECPoint) bool {
+	if point.Infinity || other.Infinity {
+		return point.Infinity == other.Infinity
+	}
+
+	return point.X.Cmp(other.X) == 0 && point.Y.Cmp(other.Y) == 0
+}
+
+/*
+	y^2 = x^3 + a * x + b over GF(p), with a base point G of prime order N.
+	Any parameters work as long as the curve isn't singular, including weak ones picked on purpose.
+*/
+type WeierstrassCurve struct {
+	P *big.Int
+	A *big.Int
+	B *big.Int
+	G ECPoint
+	N *big.Int
+}
+
+func NewWeierstrassCurve(p *big.Int, a *big.Int, b *big.Int, g ECPoint, n *big.Int) (WeierstrassCurve, error) {
+	curve := WeierstrassCurve{
+		P: p,
+		A: new(big.Int).Mod(a, p),
+		B: new(big.Int).Mod(b, p),
+		G: g,
+		N: n,
+	}
+
+	// 4a^3 + 27b^2 = 0 means a repeated root, and the chord and tangent rule breaks down
+	discriminant := new(big.Int).Exp(curve.A, big.NewInt(3), p)
+	discriminant.Mul(discriminant, big.NewInt(4))
+	discriminant.Add(discriminant, new(big.Int).Mul(big.NewInt(27), new(big.Int).Mul(curve.B, curve.B)))
+
+	if discriminant.Mod(discriminant, p).Sign() == 0 {
+		return WeierstrassCurve{}, errors.New("curve is singular")
+	}
+
+	if !curve.IsOnCurve(g) {
+		return WeierstrassCurve{}, errors.New("base point isn't on the curve")
+	}
+
+	return curve, nil
+}
+
+func mustCurve(curve WeierstrassCurve, err error) WeierstrassCurve {
+	if err != nil {
+		log.Fatalf("bad curve parameters: %v", err)
+	}
+
+	return curve
+}
+
+// y^2 = x^3 - 95051 * x + 11279326 from challenge 59
+func Challenge59Curve() WeierstrassCurve {
+	return mustCurve(NewWeierstrassCurve(
+		parseDecimalInt("233970423115425145524320034830162017933"),
+		big.NewInt(-95051),
+		big.NewInt(11279326),
+		NewECPoint(big.NewInt(182), parseDecimalInt("85518893674295321206118380980485522083")),
+		parseDecimalInt("29246302889428143187362802287225875743"),
+	))
+}
+
+// NIST P-256 with a = -3, parameters taken from crypto/elliptic
+func P256Curve() WeierstrassCurve {
+	params := elliptic.P256().Params()
+
+	return mustCurve(NewWeierstrassCurve(
+		params.P,
+		big.NewInt(-3),
+		params.B,
+		NewECPoint(params.Gx, params.Gy),
+		params.N,
+	))
+}
+
+// x^3 + a * x + b mod p, the right hand side of the curve equation
+func (curve WeierstrassCurve) rhs(x *big.Int) *big.Int {
+	result := new(big.Int).Exp(x, big.NewInt(3), curve.P)
+	result.Add(result, new(big.Int).Mul(curve.A, x))
+	result.Add(result, curve.B)
+
+	return result.Mod(result, curve.P)
+}
+
+func (curve WeierstrassCurve) IsOnCurve(point ECPoint) bool {
+	if point.Infinity {
+		return true
+	}
+
+	if point.X.Sign() < 0 || point.X.Cmp(curve.P) >= 0 || point.Y.Sign() < 0 || point.Y.Cmp(curve.P) >= 0 {
+		return false
+	}
+
+	ySquared := new(big.Int).Mul(point.Y, point.Y)
+
+	return ySquared.Mod(ySquared, curve.P).Cmp(curve.rhs(point.X)) == 0
+}
+
+func (curve WeierstrassCurve) Identity() ECPoint {
+	return ECIdentity()
+}
+
+func (curve WeierstrassCurve) Negate(point ECPoint) ECPoint {
+	if point.Infinity {
+		return point
+	}
+
+	y := new(big.Int).Neg(point.Y)
+
+	return NewECPoint(new(big.Int).Set(point.X), y.Mod(y, curve.P))
+}
+
+// Every point addition needs one, and ModInverse is about 20 times faster than InvMod here
+func fieldInverse(value *big.Int, p *big.Int) (*big.Int, error) {
+	inverse := new(big.Int).ModInverse(new(big.Int).Mod(value, p), p)
+	if inverse == nil {
+		return nil, fmt.Errorf("%v has no inverse mod %v", value, p)
+	}
+
+	return inverse, nil
+}
+
+// For curve parameters and other values that can't be 0 mod p
+func mustFieldInverse(value *big.Int, p *big.Int) *big.Int {
+	inverse, err := fieldInverse(value, p)
+	if err != nil {
+		log.Fatalf("curve arithmetic: %v", err)
+	}
+
+	return inverse
+}
+
+// Coordinates in [0, p), so x + p and x compare equal in Add
+func (curve WeierstrassCurve) reduce(point ECPoint) ECPoint {
+	if point.Infinity || (point.X.Sign() >= 0 && point.X.Cmp(curve.P) < 0 && point.Y.Sign() >= 0 && point.Y.Cmp(curve.P) < 0) {
+		return point
+	}
+
+	x := new(big.Int).Mod(point.X, curve.P)
+	y := new(big.Int).Mod(point.Y, curve.P)
+
+	return NewECPoint(x, y)
+}
+
+/*
+	Chord and tangent rule:
+		- O is the identity, P + (-P) = O
+		- m = (y2 - y1) / (x2 - x1), or (3 * x1^2 + a) / (2 * y1) when doubling
+		- x3 = m^2 - x1 - x2, y3 = m * (x1 - x3) - y1
+	Points off the curve go through the same formulas (the invalid curve attack relies on it).
+	A slope with no inverse, which only an off-curve point can cause, gives O.
+*/
+func (curve WeierstrassCurve) Add(p1 ECPoint, p2 ECPoint) ECPoint {
+	if p1.Infinity {
+		return p2
+	}
+
+	if p2.Infinity {
+		return p1
+	}
+
+	p1, p2 = curve.reduce(p1), curve.reduce(p2)
+
+	var numerator, denominator *big.Int
+	if p1.X.Cmp(p2.X) == 0 {
+		// same x means P2 is either P1 or -P1
+		ySum := new(big.Int).Add(p1.Y, p2.Y)
+		if ySum.Mod(ySum, curve.P).Sign() == 0 {
+			return ECIdentity()
+		}
+
+		numerator = new(big.Int).Mul(big.NewInt(3), new(big.Int).Mul(p1.X, p1.X))
+		numerator.Add(numerator, curve.A)
+		denominator = new(big.Int).Lsh(p1.Y, 1)
+	} else {
+		numerator = new(big.Int).Sub(p2.Y, p1.Y)
+		denominator = new(big.Int).Sub(p2.X, p1.X)
+	}
+
+	inverse, err := fieldInverse(denominator, curve.P)
+	if err != nil {
+		return ECIdentity()
+	}
+
+	m := numerator.Mul(numerator, inverse)
+	m.Mod(m, curve.P)
+
+	x3 := new(big.Int).Mul(m, m)
+	x3.Sub(x3, p1.X)
+	x3.Sub(x3, p2.X)
+	x3.Mod(x3, curve.P)
+
+	y3 := new(big.Int).Sub(p1.X, x3)
+	y3.Mul(y3, m)
+	y3.Sub(y3, p1.Y)
+	y3.Mod(y3, curve.P)
+
+	return NewECPoint(x3, y3)
+}
+
+func (curve WeierstrassCurve) Double(point ECPoint) ECPoint {
+	return curve.Add(point, point)
+}
+
+// Double and add from the top bit down, negative k multiplies -point
+func (curve WeierstrassCurve) ScalarMult(point ECPoint, k *big.Int) ECPoint {
+	if k.Sign() < 0 {
+		return curve.ScalarMult(curve.Negate(point), new(big.Int).Neg(k))
+	}
+
+	result := ECIdentity()
+
+	for i := k.BitLen() - 1; i >= 0; i-- {
+		result = curve.Double(result)
+
+		if k.Bit(i) == 1 {
+			result = curve.Add(result, point)
+		}
+	}
+
+	return result
+}
+
+func (curve WeierstrassCurve) ScalarBaseMult(k *big.Int) ECPoint {
+	return curve.ScalarMult(curve.G, k)
+}
+
+// Private key in [1, N), public key private * G
+func (curve WeierstrassCurve) GenerateKeyPair() (*big.Int, ECPoint) {
+	private := GenerateRandomBigInt(new(big.Int).Sub(curve.N, big.NewInt(1)))
+	private.Add(private, big.NewInt(1))
+
+	return private, curve.ScalarBaseMult(private)
+}
+
+// Shared point private * other. The other side's point should be validated first, see ECDHServer
+func (curve WeierstrassCurve) ECDH(private *big.Int, other ECPoint) ECPoint {
+	return curve.ScalarMult(other, private)
+}
+
+type ECDSASignature struct {
+	R *big.Int
+	S *big.Int
+}
+
+// Leftmost N.BitLen() bits of the hash, same as crypto/ecdsa
+func (curve WeierstrassCurve) hashToInt(hash []byte) *big.Int {
+	orderBytes := (curve.N.BitLen() + 7) / 8
+	if len(hash) > orderBytes {
+		hash = hash[:orderBytes]
+	}
+
+	e := new(big.Int).SetBytes(hash)
+
+	if excess := len(hash)*8 - curve.N.BitLen(); excess > 0 {
+		e.Rsh(e, uint(excess))
+	}
+
+	return e
+}
+
+/*
+	ECDSA:
+		- k random in [1, N), r = (k * G).x mod N
+		- s = (H(m) + d * r) / k mod N
+		- start over if r or s comes out as 0
+*/
+func (curve WeierstrassCurve) Sign(private *big.Int, hash []byte) ECDSASignature {
+	e := curve.hashToInt(hash)
+
+	for {
+		k, point := curve.GenerateKeyPair()
+
+		r := new(big.Int).Mod(point.X, curve.N)
+		if r.Sign() == 0 {
+			continue
+		}
+
+		kInverse, err := InvMod(k, curve.N)
+		if err != nil {
+			log.Fatalf("ECDSA nonce: %v", err)
+		}
+
+		s := new(big.Int).Mul(private, r)
+		s.Add(s, e)
+		s.Mul(s, kInverse)
+		s.Mod(s, curve.N)
+
+		if s.Sign() != 0 {
+			return ECDSASignature{R: r, S: s}
+		}
+	}
+}
+
+/*
+	ECDSA verification:
+		- r and s in [1, N)
+		- u1 = H(m) / s, u2 = r / s mod N
+		- (u1 * G + u2 * Q).x mod N == r
+*/
+func (curve WeierstrassCurve) Verify(public ECPoint, hash []byte, signature ECDSASignature) bool {
+	for _, value := range []*big.Int{signature.R, signature.S} {
+		if value.Sign() <= 0 || value.Cmp(curve.N) >= 0 {
+			return false
+		}
+	}
+
+	if public.Infinity || !curve.IsOnCurve(public) {
+		return false
+	}
+
+	w, err := InvMod(signature.S, curve.N)
+	if err != nil {
+		return false
+	}
+
+	u1 := new(big.Int).Mul(curve.hashToInt(hash), w)
+	u1.Mod(u1, curve.N)
+
+	u2 := new(big.Int).Mul(signature.R, w)
+	u2.Mod(u2, curve.N)
+
+	point := curve.Add(curve.ScalarBaseMult(u1), curve.ScalarMult(public, u2))
+	if point.Infinity {
+		return false
+	}
+
+	return new(big.Int).Mod(point.X, curve.N).Cmp(signature.R) == 0
+}
+
+// x^3 + a * x + b has a square root mod p about half the time, pick random x until it does
+func (curve WeierstrassCurve) RandomPoint() ECPoint {
+	for {
+		x := GenerateRandomBigInt(curve.P)
+
+		if y := new(big.Int).ModSqrt(curve.rhs(x), curve.P); y != nil {
+			return NewECPoint(x, y)
+		}
+	}
+}
+
+// Key material for the MAC, both coordinates so that P and -P give different keys
+func ecPointBytes(point ECPoint) []byte {
+	if point.Infinity {
+		return []byte{0}
+	}
+
+	return append(append([]byte{4}, point.X.Bytes()...), point.Y.Bytes()...)
+}
+
+/*
+	Bob from challenge 59, over an elliptic curve this time:
+		- K = x * h for whatever point h is sent in
+		- Answers with a message and its HMAC-SHA256 keyed with K
+	SkipPointValidation stops him from checking that h is actually on his curve.
+*/
+type ECDHServer struct {
+	curve               WeierstrassCurve
+	private             *big.Int
+	public              ECPoint
+	Message             []byte
+	SkipPointValidation bool
+}
+
+func NewECDHServer(curve WeierstrassCurve) *ECDHServer {
+	private, public := curve.GenerateKeyPair()
+
+	return &ECDHServer{
+		curve:   curve,
+		private: private,
+		public:  public,
+		Message: []byte("crazy flamboyant for the rap enjoyment"),
+	}
+}
+
+func (server *ECDHServer) PublicKey() ECPoint {
+	return server.public
+}
+
+func (server *ECDHServer) Respond(h ECPoint) (DHResponse, error) {
+	// even without validation, h has to be something the curve arithmetic can work with
+	if !h.Infinity {
+		if h.X == nil || h.Y == nil {
+			return DHResponse{}, errors.New("point is missing a coordinate")
+		}
+
+		if h.X.Sign() < 0 || h.X.Cmp(server.curve.P) >= 0 || h.Y.Sign() < 0 || h.Y.Cmp(server.curve.P) >= 0 {
+			return DHResponse{}, errors.New("point coordinates aren't in [0, p)")
+		}
+	}
+
+	if !server.SkipPointValidation {
+		if h.Infinity || !server.curve.IsOnCurve(h) {
+			return DHResponse{}, errors.New("point isn't on the curve")
+		}
+	}
+
+	K := server.curve.ECDH(server.private, h)
+
+	return DHResponse{Message: server.Message, MAC: hmacSHA256(ecPointBytes(K), server.Message)}, nil
+}
+
+// Curve that only differs in b, along with the order of its whole group
+type InvalidCurve struct {
+	B     *big.Int
+	Order *big.Int
+}
+
+// b values and group orders from challenge 59, for use with Challenge59Curve
+func Challenge59InvalidCurves() []InvalidCurve {
+	return []InvalidCurve{
+		{B: big.NewInt(210), Order: parseDecimalInt("233970423115425145550826547352470124412")},
+		{B: big.NewInt(504), Order: parseDecimalInt("233970423115425145544350131142039591210")},
+		{B: big.NewInt(727), Order: parseDecimalInt("233970423115425145545378039958152057148")},
+	}
+}
+
+// Same field and a, so the server's Add and ScalarMult work on it without noticing
+func (curve WeierstrassCurve) withB(b *big.Int) WeierstrassCurve {
+	return WeierstrassCurve{P: curve.P, A: curve.A, B: b, G: ECIdentity(), N: curve.N}
+}
+
+/*
+	Point of order r (r prime) on a curve whose group has the given order:
+		- Strip every factor of r out of the order, multiplying by what's left gives a point of order r^i
+		- Multiply by r until one more multiplication would give the identity
+	The group isn't always cyclic (y^2 = x^3 - 95051x + 210 has two independent points of order 2),
+	so (order / r) * random point on its own can be the identity every time.
+*/
+func smallOrderPoint(curve WeierstrassCurve, order *big.Int, r *big.Int) ECPoint {
+	cofactor := new(big.Int).Set(order)
+	for new(big.Int).Mod(cofactor, r).Sign() == 0 {
+		cofactor.Div(cofactor, r)
+	}
+
+	for {
+		point := curve.ScalarMult(curve.RandomPoint(), cofactor)
+		if point.Infinity {
+			continue
+		}
+
+		for next := curve.ScalarMult(point, r); !next.Infinity; next = curve.ScalarMult(point, r) {
+			point = next
+		}
+
+		return point
+	}
+}
+
+// K = x * h can only take r values, try b * h for every b in [0, r) against the MAC
+func recoverECResidue(curve WeierstrassCurve, response DHResponse, h ECPoint, r *big.Int) (*big.Int, error) {
+	K := ECIdentity()
+
+	for b := int64(0); b < r.Int64(); b++ {
+		if hmac.Equal(hmacSHA256(ecPointBytes(K), response.Message), response.MAC) {
+			return big.NewInt(b), nil
+		}
+
+		K = curve.Add(K, h)
+	}
+
+	return nil, fmt.Errorf("no residue mod %v matches the MAC", r)
+}
+
+/*
+	Invalid curve attack:
+		- The addition formulas never use b, so points from y^2 = x^3 + a * x + b' go through just fine
+		- Some of those curves have group orders with lots of small factors
+		- For every small prime r, send a point of order r and brute force x mod r from the MAC
+		- CRT the residues until the moduli cover the order of the real base point
+*/
+func InvalidCurveAttack(server *ECDHServer, curve WeierstrassCurve, invalidCurves []InvalidCurve) (*big.Int, error) {
+	residues := make([]*big.Int, 0)
+	moduli := make([]*big.Int, 0)
+	product := big.NewInt(1)
+	used := make(map[int64]bool)
+
+	for _, invalid := range invalidCurves {
+		related := curve.withB(invalid.B)
+
+		for _, r := range SmallFactors(invalid.Order, 1<<16) {
+			if used[r.Int64()] || product.Cmp(curve.N) > 0 {
+				continue
+			}
+
+			h := smallOrderPoint(related, invalid.Order, r)
+
+			response, err := server.Respond(h)
+			if err != nil {
+				return nil, err
+			}
+
+			residue, err := recoverECResidue(related, response, h, r)
+			if err != nil {
+				return nil, err
+			}
+
+			used[r.Int64()] = true
+			residues = append(residues, residue)
+			moduli = append(moduli, r)
+			product.Mul(product, r)
+		}
+	}
+
+	if product.Cmp(curve.N) <= 0 {
+		return nil, fmt.Errorf("small factors only cover %d of the %d bits of the order", product.BitLen()-1, curve.N.BitLen())
+	}
+
+	x, _, err := CRT(residues, moduli)
+	if err != nil {
+		return nil, err
+	}
+
+	return x.Mod(x, curve.N), nil
+}
+
+/*
+	Montgomery curve B * v^2 = u^3 + A * u^2 + u over GF(p).
+	Points reuse ECPoint with X = u and Y = v. G is the base point of prime order N,
+	Order is the size of the whole group (N times the cofactor).
+*/
+type MontgomeryCurve struct {
+	P     *big.Int
+	A     *big.Int
+	B     *big.Int
+	G     ECPoint
+	N     *big.Int
+	Order *big.Int
+}
+
+func NewMontgomeryCurve(p *big.Int, a *big.Int, b *big.Int, g ECPoint, n *big.Int, order *big.Int) (MontgomeryCurve, error) {
+	curve := MontgomeryCurve{
+		P:     p,
+		A:     new(big.Int).Mod(a, p),
+		B:     new(big.Int).Mod(b, p),
+		G:     g,
+		N:     n,
+		Order: order,
+	}
+
+	// B * (A^2 - 4) = 0 is singular
+	check := new(big.Int).Mul(curve.A, curve.A)
+	check.Sub(check, big.NewInt(4))
+	check.Mul(check, curve.B)
+
+	if check.Mod(check, p).Sign() == 0 {
+		return MontgomeryCurve{}, errors.New("curve is singular")
+	}
+
+	if !curve.IsOnCurve(g) {
+		return MontgomeryCurve{}, errors.New("base point isn't on the curve")
+	}
+
+	return curve, nil
+}
+
+/*
+	v^2 = u^3 + 534 * u^2 + u from challenge 60, the same group as Challenge59Curve:
+	u = x - 178 and the base point u = 4 is the x = 182 one.
+*/
+func Challenge60Curve() MontgomeryCurve {
+	n := parseDecimalInt("29246302889428143187362802287225875743")
+
+	curve, err := NewMontgomeryCurve(
+		parseDecimalInt("233970423115425145524320034830162017933"),
+		big.NewInt(534),
+		big.NewInt(1),
+		NewECPoint(big.NewInt(4), parseDecimalInt("85518893674295321206118380980485522083")),
+		n,
+		new(big.Int).Mul(n, big.NewInt(8)),
+	)
+	if err != nil {
+		log.Fatalf("bad curve parameters: %v", err)
+	}
+
+	return curve
+}
+
+// u^3 + A * u^2 + u mod p
+func (curve MontgomeryCurve) rhs(u *big.Int) *big.Int {
+	result := new(big.Int).Add(u, curve.A)
+	result.Mul(result, u)
+	result.Add(result, big.NewInt(1))
+	result.Mul(result, u)
+
+	return result.Mod(result, curve.P)
+}
+
+func (curve MontgomeryCurve) IsOnCurve(point ECPoint) bool {
+	if point.Infinity {
+		return true
+	}
+
+	left := new(big.Int).Mul(point.Y, point.Y)
+	left.Mul(left, curve.B)
+
+	return left.Mod(left, curve.P).Cmp(curve.rhs(point.X)) == 0
+}
+
+/*
+	Every u is either on the curve or on its quadratic twist:
+	u is on the curve when rhs(u) / B is a square mod p, which has the same Jacobi symbol as rhs(u) * B.
+*/
+func (curve MontgomeryCurve) HasU(u *big.Int) bool {
+	return big.Jacobi(new(big.Int).Mul(curve.rhs(u), curve.B), curve.P) >= 0
+}
+
+/*
+	Quadratic twist: d * B * v^2 = u^3 + A * u^2 + u for a non-square d.
+	It picks up every u the curve doesn't have, and its group has 2p + 2 - Order points.
+	There is no base point (G is the identity and N is nil).
+*/
+func (curve MontgomeryCurve) Twist() MontgomeryCurve {
+	d := big.NewInt(2)
+	for big.Jacobi(d, curve.P) != -1 {
+		d.Add(d, big.NewInt(1))
+	}
+
+	order := new(big.Int).Lsh(curve.P, 1)
+	order.Add(order, big.NewInt(2))
+	order.Sub(order, curve.Order)
+
+	return MontgomeryCurve{
+		P:     curve.P,
+		A:     curve.A,
+		B:     new(big.Int).Mod(new(big.Int).Mul(curve.B, d), curve.P),
+		G:     ECIdentity(),
+		Order: order,
+	}
+}
+
+/*
+	Weierstrass form, x = u / B + A / 3B and y = v / B, which gives:
+		- a = (3 - A^2) / 3B^2
+		- b = (2A^3 - 9A) / 27B^3
+*/
+func (curve MontgomeryCurve) ToWeierstrass() WeierstrassCurve {
+	p := curve.P
+
+	bSquared := new(big.Int).Mul(curve.B, curve.B)
+	bCubed := new(big.Int).Mul(bSquared, curve.B)
+
+	a := new(big.Int).Mul(curve.A, curve.A)
+	a.Sub(big.NewInt(3), a)
+	a.Mul(a, mustFieldInverse(new(big.Int).Mul(big.NewInt(3), bSquared), p))
+	a.Mod(a, p)
+
+	b := new(big.Int).Exp(curve.A, big.NewInt(3), nil)
+	b.Mul(b, big.NewInt(2))
+	b.Sub(b, new(big.Int).Mul(big.NewInt(9), curve.A))
+	b.Mul(b, mustFieldInverse(new(big.Int).Mul(big.NewInt(27), bCubed), p))
+	b.Mod(b, p)
+
+	return WeierstrassCurve{P: p, A: a, B: b, G: curve.ToWeierstrassPoint(curve.G), N: curve.N}
+}
+
+func (curve MontgomeryCurve) ToWeierstrassPoint(point ECPoint) ECPoint {
+	if point.Infinity {
+		return point
+	}
+
+	bInverse := mustFieldInverse(curve.B, curve.P)
+
+	x := new(big.Int).Mul(curve.A, mustFieldInverse(big.NewInt(3), curve.P))
+	x.Add(x, point.X)
+	x.Mul(x, bInverse)
+	x.Mod(x, curve.P)
+
+	y := new(big.Int).Mul(point.Y, bInverse)
+
+	return NewECPoint(x, y.Mod(y, curve.P))
+}
+
+// u = B * x - A / 3, v = B * y
+func (curve MontgomeryCurve) FromWeierstrassPoint(point ECPoint) ECPoint {
+	if point.Infinity {
+		return point
+	}
+
+	u := new(big.Int).Mul(curve.B, point.X)
+	u.Sub(u, new(big.Int).Mul(curve.A, mustFieldInverse(big.NewInt(3), curve.P)))
+	u.Mod(u, curve.P)
+
+	v := new(big.Int).Mul(curve.B, point.Y)
+
+	return NewECPoint(u, v.Mod(v, curve.P))
+}
+
+// u of a point, with the identity as 0 like the ladder returns it
+func (curve MontgomeryCurve) uOf(point ECPoint) *big.Int {
+	if point.Infinity {
+		return big.NewInt(0)
+	}
+
+	return curve.FromWeierstrassPoint(point).X
+}
+
+/*
+	Montgomery ladder on u alone:
+		- (u2 : w2) and (u3 : w3) hold k' * P and (k' + 1) * P in projective u coordinates
+		- Each bit of k doubles one and differentially adds the two, the difference is always P
+		- B never shows up, so the same code runs on the curve and on its twist
+	The identity comes back as u = 0 (which is also the point of order 2).
+*/
+func (curve MontgomeryCurve) Ladder(u *big.Int, k *big.Int) *big.Int {
+	p := curve.P
+
+	u2, w2 := big.NewInt(1), big.NewInt(0)
+	u3, w3 := new(big.Int).Mod(u, p), big.NewInt(1)
+
+	bits := p.BitLen()
+	if k.BitLen() > bits {
+		bits = k.BitLen()
+	}
+
+	mod := func(x *big.Int) *big.Int { return x.Mod(x, p) }
+
+	for i := bits - 1; i >= 0; i-- {
+		bit := k.Bit(i)
+
+		if bit == 1 {
+			u2, u3 = u3, u2
+			w2, w3 = w3, w2
+		}
+
+		// u3, w3 = (u2 * u3 - w2 * w3)^2, u * (u2 * w3 - w2 * u3)^2
+		sum := mod(new(big.Int).Sub(new(big.Int).Mul(u2, u3), new(big.Int).Mul(w2, w3)))
+		difference := mod(new(big.Int).Sub(new(big.Int).Mul(u2, w3), new(big.Int).Mul(w2, u3)))
+
+		newU3 := mod(new(big.Int).Mul(sum, sum))
+		newW3 := mod(new(big.Int).Mul(u, mod(new(big.Int).Mul(difference, difference))))
+
+		// u2, w2 = (u2^2 - w2^2)^2, 4 * u2 * w2 * (u2^2 + A * u2 * w2 + w2^2)
+		u2Squared := mod(new(big.Int).Mul(u2, u2))
+		w2Squared := mod(new(big.Int).Mul(w2, w2))
+		u2w2 := mod(new(big.Int).Mul(u2, w2))
+
+		newU2 := mod(new(big.Int).Sub(u2Squared, w2Squared))
+		newU2 = mod(newU2.Mul(newU2, newU2))
+
+		newW2 := new(big.Int).Add(u2Squared, w2Squared)
+		newW2.Add(newW2, new(big.Int).Mul(curve.A, u2w2))
+		newW2 = mod(newW2.Mul(newW2, mod(new(big.Int).Mul(big.NewInt(4), u2w2))))
+
+		u2, w2, u3, w3 = newU2, newW2, newU3, newW3
+
+		if bit == 1 {
+			u2, u3 = u3, u2
+			w2, w3 = w3, w2
+		}
+	}
+
+	if w2.Sign() == 0 {
+		return big.NewInt(0)
+	}
+
+	return mod(u2.Mul(u2, mustFieldInverse(w2, p)))
+}
+
+/*
+	X25519 style server:
+		- Public key and incoming keys are u coordinates only, K = ladder(u, x)
+		- Answers with a message and its HMAC-SHA256 keyed with K
+	Every u is a point on either the curve or its twist, so there are no invalid curves to send.
+	SkipTwistCheck stops it from rejecting u values that belong to the twist.
+*/
+type XECDHServer struct {
+	curve          MontgomeryCurve
+	private        *big.Int
+	public         *big.Int
+	Message        []byte
+	SkipTwistCheck bool
+}
+
+func NewXECDHServer(curve MontgomeryCurve) *XECDHServer {
+	private := GenerateRandomBigInt(new(big.Int).Sub(curve.N, big.NewInt(1)))
+	private.Add(private, big.NewInt(1))
+
+	return &XECDHServer{
+		curve:   curve,
+		private: private,
+		public:  curve.Ladder(curve.G.X, private),
+		Message: []byte("crazy flamboyant for the rap enjoyment"),
+	}
+}
+
+func (server *XECDHServer) PublicKey() *big.Int {
+	return server.public
+}
+
+func (server *XECDHServer) Respond(u *big.Int) (DHResponse, error) {
+	if !server.SkipTwistCheck && !server.curve.HasU(u) {
+		return DHResponse{}, errors.New("u isn't on the curve")
+	}
+
+	K := server.curve.Ladder(u, server.private)
+
+	return DHResponse{Message: server.Message, MAC: hmacSHA256(K.Bytes(), server.Message)}, nil
+}
+
+// Points of a WeierstrassCurve as a group for Pollard's kangaroo, labelled by x
+type ECKangarooGroup struct {
+	Curve WeierstrassCurve
+}
+
+func (group ECKangarooGroup) Mul(a ECPoint, b ECPoint) ECPoint {
+	return group.Curve.Add(a, b)
+}
+
+func (group ECKangarooGroup) Exp(base ECPoint, exponent *big.Int) ECPoint {
+	return group.Curve.ScalarMult(base, exponent)
+}
+
+func (group ECKangarooGroup) Equal(a ECPoint, b ECPoint) bool {
+	return a.Equal(b)
+}
+
+func (group ECKangarooGroup) Label(element ECPoint) *big.Int {
+	if element.Infinity {
+		return big.NewInt(0)
+	}
+
+	return element.X
+}
+
+type twistResidue struct {
+	modulus *big.Int
+	point   ECPoint
+	residue *big.Int
+}
+
+// Whether the residue and its negation differ, 0 and modulus / 2 look the same either way
+func (residue twistResidue) hasSign() bool {
+	doubled := new(big.Int).Lsh(residue.residue, 1)
+
+	return doubled.Mod(doubled, residue.modulus).Sign() != 0
+}
+
+/*
+	Point of order r^e, the largest power of r dividing the group order, when the r part of the group is cyclic
+	(challenge 60's twist has a point of order 4). Settles for order r when it isn't.
+*/
+func primePowerOrderPoint(curve WeierstrassCurve, order *big.Int, r *big.Int) (ECPoint, *big.Int) {
+	modulus := new(big.Int).Set(r)
+	for new(big.Int).Mod(order, new(big.Int).Mul(modulus, r)).Sign() == 0 {
+		modulus.Mul(modulus, r)
+	}
+
+	cofactor := new(big.Int).Div(order, modulus)
+	belowModulus := new(big.Int).Div(modulus, r)
+
+	for attempt := 0; attempt < 32; attempt++ {
+		point := curve.ScalarMult(curve.RandomPoint(), cofactor)
+
+		if !curve.ScalarMult(point, belowModulus).Infinity {
+			return point, modulus
+		}
+	}
+
+	return smallOrderPoint(curve, order, r), r
+}
+
+// MAC check for a guess at K, given as a point on the twist
+func twistMACMatches(twist MontgomeryCurve, response DHResponse, point ECPoint) bool {
+	return hmac.Equal(hmacSHA256(twist.uOf(point).Bytes(), response.Message), response.MAC)
+}
+
+/*
+	x mod r^e up to sign:
+		- Send u of a point h of order r^e on the twist
+		- u(b * h) = u(-b * h), so only b in [0, r^e / 2] need checking
+*/
+func recoverTwistResidue(server *XECDHServer, twist MontgomeryCurve, twistW WeierstrassCurve, r *big.Int) (twistResidue, error) {
+	h, modulus := primePowerOrderPoint(twistW, twist.Order, r)
+
+	response, err := server.Respond(twist.uOf(h))
+	if err != nil {
+		return twistResidue{}, err
+	}
+
+	K := ECIdentity()
+	half := modulus.Int64() / 2
+
+	for b := int64(0); b <= half; b++ {
+		if twistMACMatches(twist, response, K) {
+			return twistResidue{modulus: modulus, point: h, residue: big.NewInt(b)}, nil
+		}
+
+		K = twistW.Add(K, h)
+	}
+
+	return twistResidue{}, fmt.Errorf("no residue mod %v matches the MAC", modulus)
+}
+
+/*
+	Lines the sign of other's residue up with anchor's:
+		- anchor.point + other.point has order m1 * m2
+		- Of (a1, a2) and (a1, -a2) CRT'd together, only the one that matches the server's MAC is right
+		  (up to flipping both, which is the one sign left over at the end)
+*/
+func alignTwistResidue(server *XECDHServer, twist MontgomeryCurve, twistW WeierstrassCurve, anchor twistResidue, other twistResidue) (*big.Int, error) {
+	combined := twistW.Add(anchor.point, other.point)
+
+	response, err := server.Respond(twist.uOf(combined))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, residue := range []*big.Int{other.residue, new(big.Int).Sub(other.modulus, other.residue)} {
+		c, _, err := CRT([]*big.Int{anchor.residue, residue}, []*big.Int{anchor.modulus, other.modulus})
+		if err != nil {
+			return nil, err
+		}
+
+		if twistMACMatches(twist, response, twistW.ScalarMult(combined, c)) {
+			return residue, nil
+		}
+	}
+
+	return nil, fmt.Errorf("neither sign mod %v matches the MAC", other.modulus)
+}
+
+/*
+	Twist attack on x-only ECDH (challenge 60):
+		- The twist's order has small factors below bound even though the curve's doesn't
+		- Points of those orders leak x mod r^e, but only up to sign since u(b * h) = u(-b * h)
+		- Pairs of points line the signs up, so the CRT gives A with x = +-A mod R
+		- The rest is a kangaroo search on the curve, see finishTwistAttack
+*/
+func TwistAttack(server *XECDHServer, curve MontgomeryCurve, bound int64) (*big.Int, error) {
+	twist := curve.Twist()
+	twistW := twist.ToWeierstrass()
+
+	residues := make([]twistResidue, 0)
+
+	for _, r := range SmallFactors(twist.Order, bound) {
+		residue, err := recoverTwistResidue(server, twist, twistW, r)
+		if err != nil {
+			return nil, err
+		}
+
+		residues = append(residues, residue)
+	}
+
+	anchor := -1
+	for i, residue := range residues {
+		if residue.hasSign() {
+			anchor = i
+			break
+		}
+	}
+
+	if anchor < 0 {
+		return nil, errors.New("no usable residues on the twist")
+	}
+
+	values := make([]*big.Int, len(residues))
+	moduli := make([]*big.Int, len(residues))
+
+	for i, residue := range residues {
+		values[i], moduli[i] = residue.residue, residue.modulus
+
+		if i == anchor || !residue.hasSign() {
+			continue
+		}
+
+		aligned, err := alignTwistResidue(server, twist, twistW, residues[anchor], residue)
+		if err != nil {
+			return nil, err
+		}
+
+		values[i] = aligned
+	}
+
+	A, R, err := CRT(values, moduli)
+	if err != nil {
+		return nil, err
+	}
+
+	return finishTwistAttack(server, curve, A, R)
+}
+
+/*
+	What's left once x = s * A + m * R is known for an unknown sign s and m in [0, W], W = (N - 1) / R:
+		- The public u only gives Q up to sign too, Q = t * x * G
+		- With sigma = s * t, Q - sigma * A * G = t * m * (R * G), and t * m is in [-W - 1, W + 1]
+		- So one kangaroo trap over [-W - 1, W + 1] and a wild kangaroo for each sigma
+	A catch gives k = sigma * A + j * R, which is x or -x mod N. Only x is +-A mod R.
+*/
+func finishTwistAttack(server *XECDHServer, curve MontgomeryCurve, A *big.Int, R *big.Int) (*big.Int, error) {
+	curveW := curve.ToWeierstrass()
+	group := ECKangarooGroup{Curve: curveW}
+
+	// pick either square root, the sign is taken care of by t
+	x := curve.ToWeierstrassPoint(NewECPoint(server.PublicKey(), big.NewInt(0))).X
+	y := new(big.Int).ModSqrt(curveW.rhs(x), curveW.P)
+	if y == nil {
+		return nil, errors.New("public key isn't on the curve")
+	}
+
+	Q := NewECPoint(x, y)
+
+	W := new(big.Int).Sub(curve.N, big.NewInt(1))
+	W.Div(W, R)
+	W.Add(W, big.NewInt(1))
+
+	lower := new(big.Int).Neg(W)
+	width := new(big.Int).Lsh(W, 1)
+
+	trap := NewKangarooTrap[ECPoint](group, curveW.ScalarMult(curveW.G, R), W, PowerOfTwoJumpsForInterval(width))
+
+	for _, offset := range []*big.Int{A, new(big.Int).Neg(A)} {
+		j, err := trap.Catch(curveW.Add(Q, curveW.Negate(curveW.ScalarMult(curveW.G, offset))), lower)
+		if err != nil {
+			continue
+		}
+
+		k := new(big.Int).Mul(j, R)
+		k.Add(k, offset)
+
+		for _, candidate := range []*big.Int{new(big.Int).Mod(k, curve.N), new(big.Int).Mod(new(big.Int).Neg(k), curve.N)} {
+			residue := new(big.Int).Mod(candidate, R)
+
+			if residue.Cmp(A) == 0 || residue.Cmp(new(big.Int).Sub(R, A)) == 0 {
+				return candidate, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("kangaroo didn't find the rest of the key in [%v, %v]", lower, W)
+}
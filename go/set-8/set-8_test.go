@@ -0,0 +1,421 @@
+package main
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubgroupConfinementGroup(t *testing.T) {
+	group := SubgroupConfinementGroup()
+
+	assert.Nil(t, group.ValidatePublicKey(group.G))
+	assert.Zero(t, new(big.Int).Mod(new(big.Int).Sub(group.P, big.NewInt(1)), group.Q).Sign())
+
+	factors := SmallFactors(group.Cofactor(), 1<<16)
+
+	assert.Equal(t, []*big.Int{big.NewInt(2), big.NewInt(3), big.NewInt(5), big.NewInt(109)}, factors[:4])
+
+	for _, r := range factors {
+		h := SmallOrderElement(group.P, r)
+
+		assert.Equal(t, big.NewInt(1), new(big.Int).Exp(h, r, group.P))
+		assert.NotNil(t, group.ValidatePublicKey(h))
+	}
+}
+
+func TestSubgroupConfinementAttack(t *testing.T) {
+	/*
+		- Bob MACs a message with K = h^x for any h we send him
+		- Elements of small order r pin K to r possible values, which leaks x mod r
+		- Enough small factors of (p - 1) / q and the CRT gives back all of x
+	*/
+	group := SubgroupConfinementGroup()
+
+	server := NewDHServer(group)
+	server.SkipPublicKeyValidation = true
+
+	x, err := SubgroupConfinementAttack(server, group)
+
+	fmt.Printf("recovered private key: %v\n", x)
+
+	assert.Nil(t, err)
+	assert.Equal(t, server.private, x)
+	assert.Equal(t, server.PublicKey(), new(big.Int).Exp(group.G, x, group.P))
+
+	// a server that checks the order of public keys gives nothing away
+	_, err = SubgroupConfinementAttack(NewDHServer(group), group)
+
+	assert.NotNil(t, err)
+}
+
+func TestKangaroo(t *testing.T) {
+	group := KangarooChallengeGroup()
+	modP := ModPGroup{P: group.P}
+
+	// y from challenge 58, its log is in [0, 2^20]
+	y := parseDecimalInt("7760073848032689505395005705677365876654629189298052775754597607446617558600394076764814236081991643094239886772481052254010323780165093955236429914607119")
+	b := big.NewInt(1 << 20)
+
+	x, err := Kangaroo[*big.Int](modP, group.G, y, big.NewInt(0), b, PowerOfTwoJumpsForInterval(b))
+
+	assert.Nil(t, err)
+	assert.Equal(t, y, modP.Exp(group.G, x))
+
+	// somewhere in the middle of a shifted interval
+	a := big.NewInt(1 << 30)
+	b = big.NewInt(1<<30 + 1<<24)
+	want := new(big.Int).Add(a, GenerateRandomBigInt(big.NewInt(1<<24)))
+
+	x, err = Kangaroo[*big.Int](modP, group.G, modP.Exp(group.G, want), a, b, PowerOfTwoJumpsForInterval(new(big.Int).Sub(b, a)))
+
+	assert.Nil(t, err)
+	assert.Equal(t, want, x)
+}
+
+func TestKangarooCustomJumps(t *testing.T) {
+	// powers of 3 picked by the low byte of the label
+	distances := make([]*big.Int, 10)
+	for i := range distances {
+		distances[i] = new(big.Int).Exp(big.NewInt(3), big.NewInt(int64(i)), nil)
+	}
+
+	jumps := KangarooJumps{
+		Distances: distances,
+		Pick: func(label *big.Int) int {
+			return int(label.Uint64()&0xff) % len(distances)
+		},
+	}
+
+	group := SubgroupConfinementGroup()
+	modP := ModPGroup{P: group.P}
+	want := GenerateRandomBigInt(big.NewInt(1 << 24))
+
+	x, err := Kangaroo[*big.Int](modP, group.G, modP.Exp(group.G, want), big.NewInt(0), big.NewInt(1<<24), jumps)
+
+	assert.Nil(t, err)
+	assert.Equal(t, want, x)
+}
+
+func TestBruteForceDiscreteLog(t *testing.T) {
+	group := SubgroupConfinementGroup()
+	modP := ModPGroup{P: group.P}
+
+	x, err := BruteForceDiscreteLog[*big.Int](modP, group.G, modP.Exp(group.G, big.NewInt(1234)), big.NewInt(1000), big.NewInt(2000))
+
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(1234), x)
+
+	_, err = BruteForceDiscreteLog[*big.Int](modP, group.G, modP.Exp(group.G, big.NewInt(1234)), big.NewInt(0), big.NewInt(1000))
+
+	assert.NotNil(t, err)
+}
+
+func TestSubgroupConfinementKangarooAttack(t *testing.T) {
+	/*
+		- The small factors of j only cover about 88 of q's 128 bits
+		- The last 40 bits are a kangaroo search, about 2^20 steps
+	*/
+	if testing.Short() {
+		t.Skip("kangaroo over a 2^40 interval")
+	}
+
+	group := KangarooChallengeGroup()
+
+	server := NewDHServer(group)
+	server.SkipPublicKeyValidation = true
+
+	_, err := SubgroupConfinementAttack(server, group)
+	assert.NotNil(t, err)
+
+	x, err := SubgroupConfinementKangarooAttack(server, group)
+
+	fmt.Printf("recovered private key: %v\n", x)
+
+	assert.Nil(t, err)
+	assert.Equal(t, server.private, x)
+}
+
+/*
+	Kangaroo against brute force for growing intervals:
+		go test ./set-8 -run ^$ -bench DiscreteLog
+	Brute force doubles with every bit, kangaroo only grows by sqrt(2).
+*/
+func BenchmarkDiscreteLog(b *testing.B) {
+	group := KangarooChallengeGroup()
+	modP := ModPGroup{P: group.P}
+
+	for _, bits := range []int{12, 16, 20} {
+		upper := new(big.Int).Lsh(big.NewInt(1), uint(bits))
+		jumps := PowerOfTwoJumpsForInterval(upper)
+
+		b.Run(fmt.Sprintf("kangaroo/%d-bits", bits), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				y := modP.Exp(group.G, GenerateRandomBigInt(upper))
+
+				Kangaroo[*big.Int](modP, group.G, y, big.NewInt(0), upper, jumps)
+			}
+		})
+
+		b.Run(fmt.Sprintf("brute-force/%d-bits", bits), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				y := modP.Exp(group.G, GenerateRandomBigInt(upper))
+
+				BruteForceDiscreteLog[*big.Int](modP, group.G, y, big.NewInt(0), upper)
+			}
+		})
+	}
+}
+
+func TestWeierstrassCurveArithmetic(t *testing.T) {
+	curve := Challenge59Curve()
+	G := curve.G
+
+	assert.True(t, curve.IsOnCurve(G))
+	assert.True(t, curve.ScalarBaseMult(curve.N).Infinity)
+
+	assert.Equal(t, G, curve.Add(G, curve.Identity()))
+	assert.Equal(t, G, curve.Add(curve.Identity(), G))
+	assert.True(t, curve.Add(G, curve.Negate(G)).Infinity)
+
+	assert.Equal(t, curve.Double(G), curve.ScalarBaseMult(big.NewInt(2)))
+	assert.Equal(t, curve.Add(curve.Double(G), G), curve.ScalarBaseMult(big.NewInt(3)))
+	assert.Equal(t, curve.Negate(curve.ScalarBaseMult(big.NewInt(5))), curve.ScalarBaseMult(big.NewInt(-5)))
+
+	// (a + b) * G == a * G + b * G
+	a, b := GenerateRandomBigInt(curve.N), GenerateRandomBigInt(curve.N)
+
+	assert.Equal(t,
+		curve.ScalarBaseMult(new(big.Int).Add(a, b)),
+		curve.Add(curve.ScalarBaseMult(a), curve.ScalarBaseMult(b)),
+	)
+
+	assert.False(t, curve.IsOnCurve(NewECPoint(big.NewInt(182), big.NewInt(1))))
+
+	// unreduced coordinates are the same point, not a different x with no slope
+	unreduced := NewECPoint(new(big.Int).Add(G.X, curve.P), new(big.Int).Sub(G.Y, curve.P))
+
+	assert.Equal(t, curve.Double(G), curve.Add(G, unreduced))
+	assert.True(t, curve.Add(unreduced, curve.Negate(G)).Infinity)
+
+	// an off-curve point with y = 0 and the same x as one with y != 0 has no slope, that gives O
+	assert.True(t, curve.Add(NewECPoint(big.NewInt(1), big.NewInt(0)), NewECPoint(big.NewInt(1), big.NewInt(5))).Infinity)
+
+	_, err := NewWeierstrassCurve(big.NewInt(23), big.NewInt(0), big.NewInt(0), NewECPoint(big.NewInt(1), big.NewInt(1)), big.NewInt(1))
+
+	assert.NotNil(t, err)
+}
+
+func TestUserDefinedCurve(t *testing.T) {
+	// y^2 = x^3 + 2x + 3 over GF(97), (3, 6) has order 5
+	curve, err := NewWeierstrassCurve(big.NewInt(97), big.NewInt(2), big.NewInt(3), NewECPoint(big.NewInt(3), big.NewInt(6)), big.NewInt(5))
+
+	assert.Nil(t, err)
+	assert.Equal(t, NewECPoint(big.NewInt(80), big.NewInt(10)), curve.Double(curve.G))
+	assert.True(t, curve.ScalarBaseMult(big.NewInt(5)).Infinity)
+}
+
+func TestECDH(t *testing.T) {
+	curve := Challenge59Curve()
+
+	alicePrivate, alicePublic := curve.GenerateKeyPair()
+	bobPrivate, bobPublic := curve.GenerateKeyPair()
+
+	assert.Equal(t, curve.ECDH(alicePrivate, bobPublic), curve.ECDH(bobPrivate, alicePublic))
+}
+
+func TestECDHAgainstCryptoECDH(t *testing.T) {
+	curve := P256Curve()
+
+	ours, oursPublic := curve.GenerateKeyPair()
+
+	theirs, err := ecdh.P256().GenerateKey(rand.Reader)
+	assert.Nil(t, err)
+
+	// crypto/ecdh encodes points as 04 || X || Y
+	theirBytes := theirs.PublicKey().Bytes()
+	theirPublic := NewECPoint(new(big.Int).SetBytes(theirBytes[1:33]), new(big.Int).SetBytes(theirBytes[33:]))
+
+	assert.True(t, curve.IsOnCurve(theirPublic))
+
+	ourKey, err := ecdh.P256().NewPrivateKey(ours.FillBytes(make([]byte, 32)))
+	assert.Nil(t, err)
+	ourBytes := append([]byte{4}, oursPublic.X.FillBytes(make([]byte, 32))...)
+	ourBytes = append(ourBytes, oursPublic.Y.FillBytes(make([]byte, 32))...)
+	assert.Equal(t, ourBytes, ourKey.PublicKey().Bytes())
+
+	secret, err := theirs.ECDH(ourKey.PublicKey())
+	assert.Nil(t, err)
+	assert.Equal(t, secret, curve.ECDH(ours, theirPublic).X.FillBytes(make([]byte, 32)))
+}
+
+func TestECDSAAgainstCryptoECDSA(t *testing.T) {
+	/*
+		- Our signatures verify with crypto/ecdsa
+		- crypto/ecdsa signatures verify with ours
+	*/
+	curve := P256Curve()
+	hash := sha256.Sum256([]byte("Ice Ice Baby"))
+
+	private, public := curve.GenerateKeyPair()
+	signature := curve.Sign(private, hash[:])
+
+	assert.True(t, curve.Verify(public, hash[:], signature))
+
+	stdPublic := &ecdsa.PublicKey{Curve: elliptic.P256(), X: public.X, Y: public.Y}
+	assert.True(t, ecdsa.Verify(stdPublic, hash[:], signature.R, signature.S))
+
+	theirs, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+
+	r, s, err := ecdsa.Sign(rand.Reader, theirs, hash[:])
+	assert.Nil(t, err)
+
+	theirPublic := NewECPoint(theirs.X, theirs.Y)
+	assert.True(t, curve.Verify(theirPublic, hash[:], ECDSASignature{R: r, S: s}))
+
+	// tampered message and signature
+	other := sha256.Sum256([]byte("Vanilla Ice"))
+	assert.False(t, curve.Verify(theirPublic, other[:], ECDSASignature{R: r, S: s}))
+	assert.False(t, curve.Verify(theirPublic, hash[:], ECDSASignature{R: r, S: new(big.Int).Add(s, big.NewInt(1))}))
+}
+
+func TestInvalidCurveAttack(t *testing.T) {
+	/*
+		- Bob never checks that our points are on his curve
+		- Points of small order from curves with a different b pin K to a handful of values
+		- Each MAC leaks x mod r, and the CRT puts x back together
+	*/
+	curve := Challenge59Curve()
+
+	for _, invalid := range Challenge59InvalidCurves() {
+		related := curve.withB(invalid.B)
+		point := related.RandomPoint()
+
+		assert.False(t, curve.IsOnCurve(point))
+		assert.True(t, related.ScalarMult(point, invalid.Order).Infinity)
+	}
+
+	server := NewECDHServer(curve)
+	server.SkipPointValidation = true
+
+	x, err := InvalidCurveAttack(server, curve, Challenge59InvalidCurves())
+
+	fmt.Printf("recovered private key: %v\n", x)
+
+	assert.Nil(t, err)
+	assert.Equal(t, server.private, x)
+	assert.Equal(t, server.PublicKey(), curve.ScalarBaseMult(x))
+
+	// checking the point is on the curve is enough to stop it
+	_, err = InvalidCurveAttack(NewECDHServer(curve), curve, Challenge59InvalidCurves())
+
+	assert.NotNil(t, err)
+
+	// malformed points get an error rather than taking the server down
+	malformed := []ECPoint{
+		{X: big.NewInt(1)},
+		NewECPoint(new(big.Int).Add(curve.G.X, curve.P), curve.G.Y),
+		NewECPoint(curve.G.X, big.NewInt(-1)),
+	}
+
+	for _, h := range malformed {
+		_, err = server.Respond(h)
+
+		assert.NotNil(t, err)
+	}
+}
+
+func TestMontgomeryCurve(t *testing.T) {
+	curve := Challenge60Curve()
+	weierstrass := curve.ToWeierstrass()
+	challenge59 := Challenge59Curve()
+
+	// same group as challenge 59's curve, just written differently
+	assert.Equal(t, challenge59.A, weierstrass.A)
+	assert.Equal(t, challenge59.B, weierstrass.B)
+	assert.Equal(t, challenge59.G, weierstrass.G)
+	assert.Equal(t, curve.G, curve.FromWeierstrassPoint(weierstrass.G))
+
+	assert.Equal(t, big.NewInt(0), curve.Ladder(curve.G.X, curve.N))
+
+	for i := 0; i < 5; i++ {
+		k := GenerateRandomBigInt(curve.N)
+		point := curve.FromWeierstrassPoint(weierstrass.ScalarBaseMult(k))
+
+		assert.True(t, curve.IsOnCurve(point))
+		assert.Equal(t, point.X, curve.Ladder(curve.G.X, k))
+	}
+
+	// B != 1 round trips too
+	scaled, err := NewMontgomeryCurve(curve.P, curve.A, big.NewInt(3), ECIdentity(), nil, nil)
+	assert.Nil(t, err)
+
+	point := scaled.ToWeierstrass().RandomPoint()
+	assert.True(t, scaled.IsOnCurve(scaled.FromWeierstrassPoint(point)))
+	assert.Equal(t, point, scaled.ToWeierstrassPoint(scaled.FromWeierstrassPoint(point)))
+
+	_, err = NewMontgomeryCurve(curve.P, big.NewInt(2), big.NewInt(1), ECIdentity(), nil, nil)
+	assert.NotNil(t, err)
+}
+
+func TestMontgomeryTwist(t *testing.T) {
+	curve := Challenge60Curve()
+	twist := curve.Twist()
+
+	assert.Equal(t, parseDecimalInt("233970423115425145549737651362517029924"), twist.Order)
+
+	// every u belongs to one of the two
+	for i := 0; i < 20; i++ {
+		u := GenerateRandomBigInt(curve.P)
+
+		assert.NotEqual(t, curve.HasU(u), twist.HasU(u))
+	}
+
+	twistW := twist.ToWeierstrass()
+	point := twistW.RandomPoint()
+	u := twist.uOf(point)
+
+	assert.False(t, curve.HasU(u))
+	assert.Equal(t, big.NewInt(0), curve.Ladder(u, twist.Order))
+
+	// the ladder doesn't care which of the two u belongs to
+	k := GenerateRandomBigInt(twist.Order)
+	assert.Equal(t, twist.uOf(twistW.ScalarMult(point, k)), curve.Ladder(u, k))
+}
+
+func TestTwistAttack(t *testing.T) {
+	/*
+		- Bob only ever sees u, so there's no invalid curve to send, but every u not on his curve is on the twist
+		- The twist's order is full of small factors, each one leaks x mod r up to sign
+		- Lining the signs up and CRT'ing leaves about 38 bits for the kangaroo,
+		  which still takes half a minute with big.Int point arithmetic
+	*/
+	if testing.Short() {
+		t.Skip("kangaroo over a 2^38 interval on the curve")
+	}
+
+	curve := Challenge60Curve()
+
+	server := NewXECDHServer(curve)
+	server.SkipTwistCheck = true
+
+	x, err := TwistAttack(server, curve, 1<<24)
+
+	fmt.Printf("recovered private key: %v\n", x)
+
+	assert.Nil(t, err)
+	assert.Equal(t, server.private, x)
+
+	// checking that u is on the curve stops it cold
+	_, err = TwistAttack(NewXECDHServer(curve), curve, 1<<24)
+
+	assert.NotNil(t, err)
+}